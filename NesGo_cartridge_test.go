@@ -1,7 +0,0 @@
-package main
-
-import "testing"
-
-func TestVerification(t *testing.T) {
-
-}
@@ -1,7 +0,0 @@
-package main
-
-import "testing"
-
-func testResetController(t *testing.T) {
-
-}
@@ -1,7 +0,0 @@
-package main
-
-import "testing"
-
-func testResetPPU(t *testing.T) {
-
-}
@@ -0,0 +1,254 @@
+package main
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/jedgentry/NesGo/nes"
+)
+
+func TestParseBinding(t *testing.T) {
+	for _, text := range []string{"key:right shift", "pad:a", "pad1:dpup", "pad:leftx+", "pad0:triggerleft-"} {
+		b, err := parseBinding(text)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if b.String() != text {
+			t.Errorf("%q parsed back as %q", text, b.String())
+		}
+	}
+	for _, text := range []string{"", "Z", "key:", "mouse:left", "padx:a"} {
+		if _, err := parseBinding(text); err == nil {
+			t.Errorf("%q parsed without an error", text)
+		}
+	}
+}
+
+func newTestInputMap(t *testing.T, config *inputConfig) *inputMap {
+	inputs, err := newInputMap(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return inputs
+}
+
+func heldButtons(inputs *inputMap, port int) [8]bool {
+	buttons, _ := inputs.controller(port)
+	return buttons
+}
+
+func TestInputMapPorts(t *testing.T) {
+	config := defaultInputConfig()
+	config.Ports[1]["A"] = append(config.Ports[1]["A"], "pad1:a")
+	config.Ports[1]["Left"] = []string{"pad:leftx-"}
+	inputs := newTestInputMap(t, config)
+
+	inputs.handle(keyEvent("Z", true))
+	if !heldButtons(inputs, 0)[nes.ButtonA] || heldButtons(inputs, 1)[nes.ButtonA] {
+		t.Error("Z did not press A on port 1 only")
+	}
+	inputs.handle(keyEvent("Z", false))
+	if heldButtons(inputs, 0)[nes.ButtonA] {
+		t.Error("releasing Z did not release A")
+	}
+
+	inputs.handle(inputEvent{kind: inputPadButton, device: 0, name: "a", value: 1})
+	if heldButtons(inputs, 1)[nes.ButtonA] {
+		t.Error("pad 0 pressed a button bound to pad 1")
+	}
+	inputs.handle(inputEvent{kind: inputPadButton, device: 1, name: "a", value: 1})
+	if !heldButtons(inputs, 1)[nes.ButtonA] {
+		t.Error("pad 1 did not press A on port 2")
+	}
+
+	for _, test := range []struct {
+		value float64
+		left  bool
+	}{{-0.4, false}, {-0.6, true}, {0.9, false}} {
+		inputs.handle(inputEvent{kind: inputPadAxis, device: 3, name: "leftx", value: test.value})
+		if heldButtons(inputs, 1)[nes.ButtonLeft] != test.left {
+			t.Errorf("axis at %v: Left is %v", test.value, !test.left)
+		}
+	}
+}
+
+func TestInputMapHotkeys(t *testing.T) {
+	inputs := newTestInputMap(t, defaultInputConfig())
+	if events := inputs.handle(keyEvent("Tab", true)); !reflect.DeepEqual(events, []hotkeyEvent{{hotkeyFastForward, true}}) {
+		t.Errorf("pressing Tab gave %v", events)
+	}
+	if events := inputs.handle(keyEvent("Z", true)); len(events) != 0 {
+		t.Errorf("pressing Z gave %v", events)
+	}
+	if events := inputs.handle(keyEvent("Tab", false)); !reflect.DeepEqual(events, []hotkeyEvent{{hotkeyFastForward, false}}) {
+		t.Errorf("releasing Tab gave %v", events)
+	}
+	if events := inputs.handle(keyEvent("4", true)); !reflect.DeepEqual(events, []hotkeyEvent{{hotkeySlot + "4", true}}) {
+		t.Errorf("pressing 4 gave %v", events)
+	}
+
+	config := defaultInputConfig()
+	config.Hotkeys[hotkeySlot+"3"] = []string{"key:F6"}
+	inputs = newTestInputMap(t, config)
+	if events := inputs.handle(keyEvent("3", true)); len(events) != 0 {
+		t.Errorf("pressing 3 after rebinding slot 3 gave %v", events)
+	}
+	events := inputs.handle(keyEvent("F6", true))
+	if len(events) != 1 {
+		t.Fatalf("pressing F6 gave %v", events)
+	}
+	if slot, ok := hotkeySlotNumber(events[0].name); !ok || slot != 3 {
+		t.Errorf("F6 picked slot %d, want 3", slot)
+	}
+}
+
+func TestRebinder(t *testing.T) {
+	inputs := newTestInputMap(t, defaultInputConfig())
+	inputs.handle(keyEvent("F12", true))
+	r := newRebinder(inputs, 2)
+	// The key that opened the prompt is still down and must not be bound.
+	r.handle(keyEvent("F12", true))
+	r.handle(keyEvent("F12", false))
+	r.handle(keyEvent("Q", true))
+	// Holding an axis binds it once.
+	r.handle(inputEvent{kind: inputPadAxis, device: 2, name: "lefty", value: 0.8})
+	r.handle(inputEvent{kind: inputPadAxis, device: 2, name: "lefty", value: 0.9})
+	for i := 2; i < len(portInputNames)*2; i++ {
+		r.handle(keyEvent("Escape", true))
+		r.handle(keyEvent("Escape", false))
+	}
+	if !r.handle(keyEvent("Q", false)) {
+		t.Fatal("rebinding did not finish after every button")
+	}
+
+	if got := bindingList(inputs.ports[0][nes.ButtonA]); got != "key:q" {
+		t.Errorf("A is bound to %s", got)
+	}
+	if got := bindingList(inputs.ports[0][nes.ButtonB]); got != "pad2:lefty+" {
+		t.Errorf("B is bound to %s", got)
+	}
+	if got := bindingList(inputs.ports[0][nes.ButtonSelect]); got != "key:right shift, pad0:back" {
+		t.Errorf("Select is bound to %s", got)
+	}
+
+	path := filepath.Join(t.TempDir(), "input.json")
+	if err := writeInputConfig(path, inputs.config()); err != nil {
+		t.Fatal(err)
+	}
+	config, err := readInputConfig(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(newTestInputMap(t, config).ports, inputs.ports) {
+		t.Error("bindings changed going through the config file")
+	}
+}
+
+func TestPadHotPlug(t *testing.T) {
+	inputs := newTestInputMap(t, defaultInputConfig())
+	var pads padSlots
+	first, second := pads.connect(100), pads.connect(101)
+	if first != 0 || second != 1 {
+		t.Fatalf("pads got devices %d and %d", first, second)
+	}
+
+	// Each pad drives its own port by default, from the D-pad or the left stick.
+	inputs.handle(inputEvent{kind: inputPadButton, device: second, name: "b", value: 1})
+	inputs.handle(inputEvent{kind: inputPadAxis, device: second, name: "lefty", value: 0.75})
+	if buttons := heldButtons(inputs, 1); !buttons[nes.ButtonA] || !buttons[nes.ButtonDown] {
+		t.Errorf("pad 1 gave port 2 %v", buttons)
+	}
+	if buttons := heldButtons(inputs, 0); buttons != [8]bool{} {
+		t.Errorf("pad 1 gave port 1 %v", buttons)
+	}
+
+	device, ok := pads.disconnect(101)
+	if !ok || device != second {
+		t.Fatal("pad 1 was not connected")
+	}
+	for _, event := range inputs.release(device) {
+		inputs.handle(event)
+	}
+	if buttons := heldButtons(inputs, 1); buttons != [8]bool{} {
+		t.Errorf("buttons held after unplugging: %v", buttons)
+	}
+	if _, ok := pads.device(101); ok {
+		t.Error("unplugged pad still has a device")
+	}
+	if device := pads.connect(102); device != second {
+		t.Errorf("new pad got device %d instead of the free %d", device, second)
+	}
+}
+
+func TestPadLayouts(t *testing.T) {
+	inputs := newTestInputMap(t, defaultInputConfig())
+
+	// A Nintendo pad's A is on the right like the NES's, so it presses A by default.
+	inputs.handle(inputEvent{kind: inputPadButton, device: 0, name: padButtonName(padNintendo, "a"), value: 1})
+	if buttons := heldButtons(inputs, 0); !buttons[nes.ButtonA] || buttons[nes.ButtonB] {
+		t.Errorf("Nintendo pad's A gave %v", buttons)
+	}
+	inputs.handle(inputEvent{kind: inputPadButton, device: 0, name: padButtonName(padNintendo, "a"), value: 0})
+	inputs.handle(inputEvent{kind: inputPadButton, device: 0, name: padButtonName(padXbox, "a"), value: 1})
+	if buttons := heldButtons(inputs, 0); buttons[nes.ButtonA] || !buttons[nes.ButtonB] {
+		t.Errorf("Xbox pad's A gave %v", buttons)
+	}
+	if name := padButtonName(padNintendo, "start"); name != "start" {
+		t.Errorf("Nintendo pad's start is %q", name)
+	}
+}
+
+func TestInputMapTurbo(t *testing.T) {
+	config := defaultInputConfig()
+	config.Autofire = [][]string{{}, {"B"}}
+	inputs := newTestInputMap(t, config)
+
+	inputs.handle(keyEvent("A", true))
+	if buttons, turbo := inputs.controller(0); buttons[nes.ButtonA] || !turbo[nes.ButtonA] {
+		t.Errorf("Turbo A gave buttons %v and turbo %v", buttons, turbo)
+	}
+	inputs.handle(keyEvent("U", true))
+	if buttons, turbo := inputs.controller(1); buttons[nes.ButtonB] || !turbo[nes.ButtonB] {
+		t.Errorf("autofire B gave buttons %v and turbo %v", buttons, turbo)
+	}
+
+	saved := newTestInputMap(t, inputs.config())
+	if !reflect.DeepEqual(saved.autofire, inputs.autofire) || saved.turboRate != nes.DefaultTurboRate {
+		t.Errorf("autofire %v at rate %d after going through the config", saved.autofire, saved.turboRate)
+	}
+	config.Autofire = [][]string{{"Turbo A"}}
+	if _, err := newInputMap(config); err == nil {
+		t.Error("Turbo A was accepted as an autofire button")
+	}
+}
+
+func TestTyping(t *testing.T) {
+	inputs = newTestInputMap(t, defaultInputConfig())
+	familyKeyboard = nes.NewFamilyKeyboard()
+	defer func() { inputs, familyKeyboard, typing = nil, nil, false }()
+	keys := func() [nes.MovieInputSize]byte {
+		var input [nes.MovieInputSize]byte
+		familyKeyboard.MovieInput(&input)
+		return input
+	}
+
+	inputs.handle(keyEvent("X", true))
+	toggleTyping()
+	if heldButtons(inputs, 0)[nes.ButtonA] || heldButtons(inputs, 0)[nes.ButtonB] {
+		t.Error("controller buttons still held after typing started")
+	}
+	if !typeKey(keyEvent("Z", true)) || heldButtons(inputs, 0)[nes.ButtonA] {
+		t.Error("Z pressed A while typing")
+	}
+	if keys()[nes.KeyZ/8]&(1<<(nes.KeyZ%8)) == 0 {
+		t.Error("Z did not press Z on the Family BASIC keyboard")
+	}
+	if typeKey(keyEvent("F10", true)) {
+		t.Error("the keyboard hotkey was typed rather than left to turn typing off")
+	}
+	toggleTyping()
+	if typing || keys() != [nes.MovieInputSize]byte{} {
+		t.Error("keys still held after typing stopped")
+	}
+}
@@ -0,0 +1,189 @@
+package main
+
+import (
+	"flag"
+	"log"
+	"sync"
+
+	"github.com/gordonklaus/portaudio"
+	"github.com/jedgentry/NesGo/nes"
+)
+
+var audioBackend = flag.String("audio", "sdl", "audio output: sdl, portaudio or none")
+var sampleRate = flag.Int("sample-rate", 48000, "audio sample rate in Hz")
+var outputFilter = flag.String("filter", "nes", "analog output filters: nes, famicom or none")
+
+//audioLatency is the most audio, in seconds, a backend queues before it starts dropping samples.
+const audioLatency = 0.1
+
+//audioSink is an nes.AudioSink that can be shut down.
+type audioSink interface {
+	nes.AudioSink
+	Close() error
+}
+
+var audio audioSink
+
+//filterInit applies -filter. It runs before capturing starts, so captures are filtered too.
+func filterInit() {
+	switch *outputFilter {
+	case "nes":
+		system.SetFilter(nes.FilterNES)
+	case "famicom":
+		system.SetFilter(nes.FilterFamicom)
+	case "none":
+		system.SetFilter(nes.FilterNone)
+	default:
+		log.Printf("Unknown filter %q", *outputFilter)
+	}
+}
+
+func audioInit() {
+	var err error
+	switch *audioBackend {
+	case "sdl":
+		audio, err = NewSDLAudio(*sampleRate)
+	case "portaudio":
+		audio, err = NewAudio(*sampleRate)
+	case "none":
+		return
+	default:
+		log.Printf("Unknown audio backend %q", *audioBackend)
+		return
+	}
+	if err != nil {
+		log.Println(err)
+		audio = nil
+		return
+	}
+	updateAudioSink()
+}
+
+func audioCleanup() {
+	if audio == nil {
+		return
+	}
+	if err := audio.Close(); err != nil {
+		log.Println(err)
+	}
+	audio = nil
+	updateAudioSink()
+}
+
+//teeSink hands the same samples to several sinks running at one rate.
+type teeSink []nes.AudioSink
+
+func (sinks teeSink) SampleRate() int {
+	return sinks[0].SampleRate()
+}
+
+func (sinks teeSink) WriteSamples(samples []float32) {
+	for _, sink := range sinks {
+		sink.WriteSamples(samples)
+	}
+}
+
+//updateAudioSink points the emulator at whichever of playback and capture are running.
+func updateAudioSink() {
+	var sinks teeSink
+	if audio != nil {
+		sinks = append(sinks, audio)
+	}
+	if capture != nil {
+		sinks = append(sinks, capture.sink)
+	}
+	switch len(sinks) {
+	case 0:
+		system.SetAudioSink(nil)
+	case 1:
+		system.SetAudioSink(sinks[0])
+	default:
+		system.SetAudioSink(sinks)
+	}
+}
+
+//Audio plays samples through PortAudio. The stream pulls from a ring buffer that the
+//emulator fills once per frame.
+type Audio struct {
+	stream         *portaudio.Stream
+	sampleRate     int
+	outputChannels int
+
+	lock   sync.Mutex
+	buffer []float32
+	read   int
+	length int
+}
+
+func NewAudio(sampleRate int) (*Audio, error) {
+	if err := portaudio.Initialize(); err != nil {
+		return nil, err
+	}
+	a := &Audio{sampleRate: sampleRate}
+	a.buffer = make([]float32, int(float64(sampleRate)*audioLatency))
+	if err := a.Start(); err != nil {
+		portaudio.Terminate()
+		return nil, err
+	}
+	return a, nil
+}
+
+func (a *Audio) Start() error {
+	host, err := portaudio.DefaultHostApi()
+	if err != nil {
+		return err
+	}
+	parameters := portaudio.HighLatencyParameters(nil, host.DefaultOutputDevice)
+	parameters.SampleRate = float64(a.sampleRate)
+	stream, err := portaudio.OpenStream(parameters, a.Callback)
+	if err != nil {
+		return err
+	}
+	if err := stream.Start(); err != nil {
+		return err
+	}
+	a.stream = stream
+	a.outputChannels = parameters.Output.Channels
+	return nil
+}
+
+func (a *Audio) Close() error {
+	err := a.stream.Close()
+	portaudio.Terminate()
+	return err
+}
+
+func (a *Audio) SampleRate() int {
+	return a.sampleRate
+}
+
+//WriteSamples queues samples for playback, dropping whatever doesn't fit.
+func (a *Audio) WriteSamples(samples []float32) {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	for _, sample := range samples {
+		if a.length == len(a.buffer) {
+			return
+		}
+		a.buffer[(a.read+a.length)%len(a.buffer)] = sample
+		a.length++
+	}
+}
+
+func (a *Audio) Callback(out []float32) {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	var output float32
+	for i := range out {
+		if i%a.outputChannels == 0 {
+			if a.length > 0 {
+				output = a.buffer[a.read]
+				a.read = (a.read + 1) % len(a.buffer)
+				a.length--
+			} else {
+				output = 0
+			}
+		}
+		out[i] = output
+	}
+}
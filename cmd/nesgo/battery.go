@@ -0,0 +1,77 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+var autosaveInterval = flag.Duration("autosave", time.Minute, "how often to write battery saves to disk (0 disables)")
+
+var lastAutosave time.Time
+var lastBattery []byte
+
+//batteryPath returns the .sav file next to the ROM.
+func batteryPath() string {
+	return strings.TrimSuffix(romPath, filepath.Ext(romPath)) + ".sav"
+}
+
+func loadBattery() {
+	lastAutosave = time.Now()
+	if !system.HasBattery() {
+		return
+	}
+	file, err := os.Open(batteryPath())
+	if os.IsNotExist(err) {
+		return
+	}
+	if err != nil {
+		log.Println(err)
+		return
+	}
+	defer file.Close()
+	if err := system.LoadBattery(file); err != nil {
+		log.Println(err)
+		return
+	}
+	lastBattery = batterySnapshot()
+}
+
+//saveBattery writes the battery RAM if it changed since the last write.
+func saveBattery() {
+	lastAutosave = time.Now()
+	if !system.HasBattery() || movieActive() {
+		return
+	}
+	snapshot := batterySnapshot()
+	if bytes.Equal(snapshot, lastBattery) {
+		return
+	}
+	// Write to a temporary file first so a crash can't leave a half written save.
+	path := batteryPath()
+	if err := os.WriteFile(path+".tmp", snapshot, 0644); err != nil {
+		log.Println(err)
+		return
+	}
+	if err := os.Rename(path+".tmp", path); err != nil {
+		log.Println(err)
+		return
+	}
+	lastBattery = snapshot
+}
+
+func autosaveBattery() {
+	if *autosaveInterval > 0 && time.Since(lastAutosave) >= *autosaveInterval {
+		saveBattery()
+	}
+}
+
+func batterySnapshot() []byte {
+	var snapshot bytes.Buffer
+	system.SaveBattery(&snapshot)
+	return snapshot.Bytes()
+}
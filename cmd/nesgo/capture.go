@@ -0,0 +1,118 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/jedgentry/NesGo/nes"
+)
+
+var capturePath = flag.String("capture", "", "record audio to a .wav file, or raw 32-bit float samples for any other extension")
+var captureChannels = flag.Bool("capture-channels", false, "with -capture or F8, also record each APU channel to its own file")
+
+//captureFile is an audio capture sink and the file it writes to.
+type captureFile struct {
+	file *os.File
+	sink audioSink
+}
+
+func openCapture(path string) (*captureFile, error) {
+	file, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	capture := &captureFile{file: file}
+	if strings.EqualFold(filepath.Ext(path), ".wav") {
+		capture.sink, err = nes.NewWAVWriter(file, *sampleRate)
+		if err != nil {
+			file.Close()
+			return nil, err
+		}
+	} else {
+		capture.sink = nes.NewRawWriter(file, *sampleRate)
+	}
+	return capture, nil
+}
+
+func (capture *captureFile) Close() error {
+	err := capture.sink.Close()
+	if closeErr := capture.file.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+var capture *captureFile
+var channelCaptures []*captureFile
+
+//channelCapturePath names a channel's file after the mix, e.g. music-pulse1.wav for music.wav.
+func channelCapturePath(path string, channel int) string {
+	ext := filepath.Ext(path)
+	return strings.TrimSuffix(path, ext) + "-" + nes.ChannelNames[channel] + ext
+}
+
+func captureStart(path string) {
+	var err error
+	capture, err = openCapture(path)
+	if err != nil {
+		log.Println(err)
+		return
+	}
+	if *captureChannels {
+		for channel := range nes.ChannelNames {
+			if channel == nes.ChannelExpansion && !system.HasAudioExpansion() {
+				continue
+			}
+			channelCapture, err := openCapture(channelCapturePath(path, channel))
+			if err != nil {
+				log.Println(err)
+				continue
+			}
+			channelCaptures = append(channelCaptures, channelCapture)
+			system.SetChannelSink(channel, channelCapture.sink)
+		}
+	}
+	updateAudioSink()
+	log.Printf("Capturing audio to %s", path)
+}
+
+func captureStop() {
+	if capture == nil {
+		return
+	}
+	for channel := range nes.ChannelNames {
+		system.SetChannelSink(channel, nil)
+	}
+	for _, channelCapture := range channelCaptures {
+		if err := channelCapture.Close(); err != nil {
+			log.Println(err)
+		}
+	}
+	channelCaptures = nil
+	if err := capture.Close(); err != nil {
+		log.Println(err)
+	}
+	capture = nil
+	updateAudioSink()
+	log.Println("Stopped capturing audio")
+}
+
+//toggleCapture starts or stops a capture named after the ROM and the current time.
+func toggleCapture() {
+	if capture != nil {
+		captureStop()
+		return
+	}
+	captureStart(fmt.Sprintf("%s.%s.wav", romPath, time.Now().Format("20060102-150405")))
+}
+
+func captureInit() {
+	if *capturePath != "" {
+		captureStart(*capturePath)
+	}
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"math"
+
+	"github.com/jedgentry/NesGo/nes"
+	"github.com/veandco/go-sdl2/sdl"
+)
+
+var port2Device = flag.String("port2", "controller", "device in controller port 2: controller, zapper or vaus")
+var expansionDevice = flag.String("expansion", "none", "device in the Famicom expansion port: none, vaus or keyboard")
+var multitapFlag = flag.String("multitap", "none", "adapter for players 3 and 4: none, fourscore or famicom")
+var vausSensitivity = flag.Float64("vaus-sensitivity", 0.5, "Vaus knob steps per pixel of mouse movement")
+
+var multitaps = map[string]int{
+	"none":      nes.MultitapNone,
+	"fourscore": nes.MultitapFourScore,
+	"famicom":   nes.MultitapFamicom,
+}
+
+var zapper *nes.Zapper
+var vaus *nes.Vaus
+
+//vausPosition keeps the fractions of a knob step the mouse has moved.
+var vausPosition float64
+
+//devicesInit plugs in the devices picked on the command line.
+func devicesInit() {
+	multitap, ok := multitaps[*multitapFlag]
+	if !ok {
+		check(fmt.Errorf("unknown -multitap %q", *multitapFlag))
+	}
+	if multitap == nes.MultitapFourScore && *port2Device != "controller" {
+		check(fmt.Errorf("the Four Score uses port 2, so -port2 can't be used with it"))
+	}
+	if multitap == nes.MultitapFamicom && *expansionDevice != "none" {
+		check(fmt.Errorf("the Famicom multitap uses the expansion port, so -expansion can't be used with it"))
+	}
+	system.SetMultitap(multitap)
+
+	switch *port2Device {
+	case "controller":
+	case "zapper":
+		zapper = nes.NewZapper()
+		system.SetInputDevice(1, zapper)
+	case "vaus":
+		vaus = nes.NewVaus(false)
+		system.SetInputDevice(1, vaus)
+	default:
+		check(fmt.Errorf("unknown -port2 device %q", *port2Device))
+	}
+
+	switch *expansionDevice {
+	case "none":
+	case "vaus":
+		if vaus != nil {
+			check(fmt.Errorf("only one Vaus can be plugged in"))
+		}
+		vaus = nes.NewVaus(true)
+		system.SetInputDevice(nes.ExpansionPort, vaus)
+	case "keyboard":
+		familyKeyboard = nes.NewFamilyKeyboard()
+		system.SetInputDevice(nes.ExpansionPort, familyKeyboard)
+	default:
+		check(fmt.Errorf("unknown -expansion device %q", *expansionDevice))
+	}
+	if vaus != nil {
+		vausPosition = float64(vaus.Position())
+	}
+}
+
+//mouseInit keeps the mouse in the window while it turns the Vaus knob.
+func mouseInit() {
+	if vaus != nil {
+		sdl.SetRelativeMouseMode(true)
+	}
+}
+
+//mouseInput drives the Zapper and the Vaus. The window shows every pixel scale times over.
+func mouseInput(event sdl.Event) {
+	switch t := event.(type) {
+	case *sdl.MouseMotionEvent:
+		if zapper != nil {
+			zapper.Aim(int(t.X)/scale, int(t.Y)/scale)
+		}
+		if vaus != nil {
+			vausPosition += float64(t.XRel) * *vausSensitivity
+			vausPosition = math.Max(nes.VausMin, math.Min(nes.VausMax, vausPosition))
+			vaus.SetPosition(int(vausPosition))
+		}
+	case *sdl.MouseButtonEvent:
+		if t.Button != sdl.BUTTON_LEFT {
+			break
+		}
+		if zapper != nil {
+			zapper.SetTrigger(t.State == sdl.PRESSED)
+		}
+		if vaus != nil {
+			vaus.SetFire(t.State == sdl.PRESSED)
+		}
+	case *sdl.WindowEvent:
+		if zapper != nil && t.Event == sdl.WINDOWEVENT_LEAVE {
+			zapper.Aim(-1, -1)
+		}
+	}
+}
@@ -0,0 +1,619 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/jedgentry/NesGo/nes"
+)
+
+//inputKind is the kind of physical input a binding refers to.
+type inputKind int
+
+const (
+	inputKey inputKind = iota
+	inputPadButton
+	inputPadAxis
+)
+
+//anyDevice in a binding matches every game pad.
+const anyDevice = -1
+
+//binding is one physical input that a button or hotkey is bound to. It is written in the
+//config file as "key:<SDL key name>", "pad:<button>" or "pad:<axis>+" / "pad:<axis>-",
+//where "pad" matches any game pad and "pad0", "pad1"... match one in the order they were plugged in.
+type binding struct {
+	kind   inputKind
+	device int
+	name   string
+	//sign is the direction an axis has to be pushed in, 1 or -1.
+	sign int
+}
+
+func parseBinding(text string) (binding, error) {
+	colon := strings.IndexByte(text, ':')
+	if colon < 0 || colon == len(text)-1 {
+		return binding{}, fmt.Errorf("input binding %q is not device:input", text)
+	}
+	device, name := strings.ToLower(text[:colon]), text[colon+1:]
+	if device == "key" {
+		return binding{kind: inputKey, name: strings.ToLower(name)}, nil
+	}
+	if !strings.HasPrefix(device, "pad") {
+		return binding{}, fmt.Errorf("input binding %q has unknown device %q", text, device)
+	}
+	b := binding{kind: inputPadButton, device: anyDevice, name: strings.ToLower(name)}
+	if device != "pad" {
+		index, err := strconv.Atoi(device[len("pad"):])
+		if err != nil || index < 0 {
+			return binding{}, fmt.Errorf("input binding %q has unknown device %q", text, device)
+		}
+		b.device = index
+	}
+	switch b.name[len(b.name)-1] {
+	case '+':
+		b.kind, b.sign, b.name = inputPadAxis, 1, b.name[:len(b.name)-1]
+	case '-':
+		b.kind, b.sign, b.name = inputPadAxis, -1, b.name[:len(b.name)-1]
+	}
+	return b, nil
+}
+
+func (b binding) String() string {
+	if b.kind == inputKey {
+		return "key:" + b.name
+	}
+	device := "pad"
+	if b.device != anyDevice {
+		device += strconv.Itoa(b.device)
+	}
+	text := device + ":" + b.name
+	switch {
+	case b.kind == inputPadAxis && b.sign > 0:
+		text += "+"
+	case b.kind == inputPadAxis:
+		text += "-"
+	}
+	return text
+}
+
+//inputEvent is a change in one physical input, independent of SDL so tests can make their own.
+//Keys and buttons have a value of 0 or 1, axes go from -1 to 1.
+type inputEvent struct {
+	kind   inputKind
+	device int
+	name   string
+	value  float64
+}
+
+func keyEvent(name string, pressed bool) inputEvent {
+	event := inputEvent{kind: inputKey, name: strings.ToLower(name)}
+	if pressed {
+		event.value = 1
+	}
+	return event
+}
+
+//hotkeyEvent is a hotkey being pressed or released.
+type hotkeyEvent struct {
+	name    string
+	pressed bool
+}
+
+//The hotkeys the frontend understands.
+const (
+	hotkeyPause       = "pause"
+	hotkeyReset       = "reset"
+	hotkeySaveState   = "save-state"
+	hotkeyLoadState   = "load-state"
+	hotkeyFastForward = "fast-forward"
+	hotkeyRewind      = "rewind"
+	hotkeyDebug       = "debug"
+	hotkeyCapture     = "capture"
+	hotkeyRebind      = "rebind"
+	hotkeyKeyboard    = "keyboard"
+	//hotkeySlot and a number from 1 to stateSlots name the hotkeys that pick a save state slot.
+	hotkeySlot = "slot-"
+)
+
+//stateSlots is the number of save state slots.
+const stateSlots = 9
+
+var hotkeyNames = append([]string{
+	hotkeyPause, hotkeyReset, hotkeySaveState, hotkeyLoadState, hotkeyFastForward,
+	hotkeyRewind, hotkeyDebug, hotkeyCapture, hotkeyRebind, hotkeyKeyboard,
+}, slotHotkeys()...)
+
+func slotHotkeys() []string {
+	names := make([]string, stateSlots)
+	for i := range names {
+		names[i] = hotkeySlot + strconv.Itoa(i+1)
+	}
+	return names
+}
+
+//hotkeySlotNumber returns the save state slot a slot hotkey picks.
+func hotkeySlotNumber(name string) (int, bool) {
+	if !strings.HasPrefix(name, hotkeySlot) {
+		return 0, false
+	}
+	slot, err := strconv.Atoi(strings.TrimPrefix(name, hotkeySlot))
+	return slot, err == nil
+}
+
+//Inputs of a port after the eight controller buttons.
+const (
+	turboA = iota + 8
+	turboB
+	portInputCount
+)
+
+//portInputNames are the names used in the config file for the inputs of a port.
+var portInputNames = [portInputCount]string{
+	nes.ButtonA:      "A",
+	nes.ButtonB:      "B",
+	nes.ButtonSelect: "Select",
+	nes.ButtonStart:  "Start",
+	nes.ButtonUp:     "Up",
+	nes.ButtonDown:   "Down",
+	nes.ButtonLeft:   "Left",
+	nes.ButtonRight:  "Right",
+	turboA:           "Turbo A",
+	turboB:           "Turbo B",
+}
+
+//inputConfig is the JSON input config file.
+type inputConfig struct {
+	//AxisThreshold is how far an axis has to be pushed, from 0 to 1, to count as pressed.
+	AxisThreshold float64 `json:"axis_threshold"`
+	//Ports maps button names to bindings for each player. Players 3 and 4 are connected through a multitap.
+	Ports []map[string][]string `json:"ports"`
+	//Hotkeys maps hotkey names to bindings.
+	Hotkeys map[string][]string `json:"hotkeys"`
+	//TurboRate is the autofire rate in frames per press.
+	TurboRate int `json:"turbo_rate"`
+	//Autofire lists, for each port, the buttons that autofire whenever they are held.
+	Autofire [][]string `json:"autofire"`
+}
+
+//padDefaults are the bindings every port gets for the game pad with the same number.
+//Face buttons are named by their position on an Xbox pad, so "b" is on the right where the NES has A.
+var padDefaults = map[string][]string{
+	"A": {"b"}, "B": {"a"}, "Select": {"back"}, "Start": {"start"},
+	"Up": {"dpup", "lefty-"}, "Down": {"dpdown", "lefty+"}, "Left": {"dpleft", "leftx-"}, "Right": {"dpright", "leftx+"},
+	"Turbo A": {"y"}, "Turbo B": {"x"},
+}
+
+func defaultInputConfig() *inputConfig {
+	config := &inputConfig{
+		AxisThreshold: 0.5,
+		TurboRate:     nes.DefaultTurboRate,
+		Ports: []map[string][]string{
+			{
+				"A": {"key:Z"}, "B": {"key:X"}, "Select": {"key:Right Shift"}, "Start": {"key:Return"},
+				"Up": {"key:Up"}, "Down": {"key:Down"}, "Left": {"key:Left"}, "Right": {"key:Right"},
+				"Turbo A": {"key:A"}, "Turbo B": {"key:S"},
+			},
+			{
+				"A": {"key:O"}, "B": {"key:U"}, "Select": {"key:Y"}, "Start": {"key:P"},
+				"Up": {"key:I"}, "Down": {"key:K"}, "Left": {"key:J"}, "Right": {"key:L"},
+			},
+			// Players 3 and 4, through a multitap, only have game pads until bound to something else.
+			{},
+			{},
+		},
+		Hotkeys: map[string][]string{
+			hotkeyPause:       {"key:Space"},
+			hotkeyReset:       {"key:F3"},
+			hotkeySaveState:   {"key:F5"},
+			hotkeyLoadState:   {"key:F9"},
+			hotkeyFastForward: {"key:Tab"},
+			hotkeyRewind:      {"key:Backspace"},
+			hotkeyDebug:       {"key:`"},
+			hotkeyCapture:     {"key:F8"},
+			hotkeyRebind:      {"key:F12"},
+			hotkeyKeyboard:    {"key:F10"},
+		},
+	}
+	for slot, name := range slotHotkeys() {
+		config.Hotkeys[name] = []string{"key:" + strconv.Itoa(slot+1)}
+	}
+	for port, buttons := range config.Ports {
+		for button, names := range padDefaults {
+			for _, name := range names {
+				buttons[button] = append(buttons[button], fmt.Sprintf("pad%d:%s", port, name))
+			}
+		}
+	}
+	return config
+}
+
+//readInputConfig reads a config file, falling back to the defaults if there is none.
+func readInputConfig(path string) (*inputConfig, error) {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return defaultInputConfig(), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	config := defaultInputConfig()
+	if err := json.Unmarshal(data, config); err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	return config, nil
+}
+
+func writeInputConfig(path string, config *inputConfig) error {
+	data, err := json.MarshalIndent(config, "", "\t")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0644)
+}
+
+//physicalInput identifies one pressable input: a key, a pad button or one direction of a pad axis.
+type physicalInput struct {
+	kind   inputKind
+	device int
+	name   string
+	sign   int
+}
+
+//inputMap turns physical input events into controller buttons and hotkeys.
+type inputMap struct {
+	ports         [][portInputCount][]binding
+	hotkeys       map[string][]binding
+	axisThreshold float64
+	turboRate     int
+	autofire      [][8]bool
+	pressed       map[physicalInput]bool
+	held          map[string]bool
+}
+
+func newInputMap(config *inputConfig) (*inputMap, error) {
+	inputs := &inputMap{
+		ports:         make([][portInputCount][]binding, len(config.Ports)),
+		hotkeys:       map[string][]binding{},
+		axisThreshold: config.AxisThreshold,
+		turboRate:     config.TurboRate,
+		autofire:      make([][8]bool, len(config.Autofire)),
+		pressed:       map[physicalInput]bool{},
+		held:          map[string]bool{},
+	}
+	for port, buttons := range config.Ports {
+		for name, texts := range buttons {
+			button := buttonIndex(name)
+			if button < 0 {
+				return nil, fmt.Errorf("port %d: unknown button %q", port+1, name)
+			}
+			for _, text := range texts {
+				b, err := parseBinding(text)
+				if err != nil {
+					return nil, err
+				}
+				inputs.ports[port][button] = append(inputs.ports[port][button], b)
+			}
+		}
+	}
+	for port, names := range config.Autofire {
+		for _, name := range names {
+			button := buttonIndex(name)
+			if button < 0 || button >= len(inputs.autofire[port]) {
+				return nil, fmt.Errorf("port %d: unknown autofire button %q", port+1, name)
+			}
+			inputs.autofire[port][button] = true
+		}
+	}
+	for name, texts := range config.Hotkeys {
+		if !isHotkey(name) {
+			return nil, fmt.Errorf("unknown hotkey %q", name)
+		}
+		for _, text := range texts {
+			b, err := parseBinding(text)
+			if err != nil {
+				return nil, err
+			}
+			inputs.hotkeys[name] = append(inputs.hotkeys[name], b)
+		}
+	}
+	return inputs, nil
+}
+
+func buttonIndex(name string) int {
+	for i, buttonName := range portInputNames {
+		if strings.EqualFold(name, buttonName) {
+			return i
+		}
+	}
+	return -1
+}
+
+func isHotkey(name string) bool {
+	for _, hotkey := range hotkeyNames {
+		if name == hotkey {
+			return true
+		}
+	}
+	return false
+}
+
+//config returns the bindings as a config file.
+func (inputs *inputMap) config() *inputConfig {
+	config := &inputConfig{AxisThreshold: inputs.axisThreshold, TurboRate: inputs.turboRate, Hotkeys: map[string][]string{}}
+	for _, port := range inputs.ports {
+		buttons := map[string][]string{}
+		for button, bindings := range port {
+			for _, b := range bindings {
+				buttons[portInputNames[button]] = append(buttons[portInputNames[button]], b.String())
+			}
+		}
+		config.Ports = append(config.Ports, buttons)
+	}
+	for _, autofire := range inputs.autofire {
+		names := []string{}
+		for button, on := range autofire {
+			if on {
+				names = append(names, portInputNames[button])
+			}
+		}
+		config.Autofire = append(config.Autofire, names)
+	}
+	for name, bindings := range inputs.hotkeys {
+		for _, b := range bindings {
+			config.Hotkeys[name] = append(config.Hotkeys[name], b.String())
+		}
+	}
+	return config
+}
+
+//physical returns the inputs an event sets, with whether each is now pressed.
+//An axis event sets both of its directions.
+func (inputs *inputMap) physical(event inputEvent) map[physicalInput]bool {
+	if event.kind != inputPadAxis {
+		input := physicalInput{kind: event.kind, device: event.device, name: event.name}
+		return map[physicalInput]bool{input: event.value > 0.5}
+	}
+	positive := physicalInput{kind: inputPadAxis, device: event.device, name: event.name, sign: 1}
+	negative := positive
+	negative.sign = -1
+	return map[physicalInput]bool{
+		positive: event.value > inputs.axisThreshold,
+		negative: event.value < -inputs.axisThreshold,
+	}
+}
+
+//handle records an event and returns the hotkeys it pressed or released.
+func (inputs *inputMap) handle(event inputEvent) []hotkeyEvent {
+	for input, pressed := range inputs.physical(event) {
+		if pressed {
+			inputs.pressed[input] = true
+		} else {
+			delete(inputs.pressed, input)
+		}
+	}
+	var events []hotkeyEvent
+	for _, name := range hotkeyNames {
+		pressed := inputs.active(inputs.hotkeys[name])
+		if pressed != inputs.held[name] {
+			inputs.held[name] = pressed
+			events = append(events, hotkeyEvent{name: name, pressed: pressed})
+		}
+	}
+	return events
+}
+
+//active reports whether any of bindings is pressed.
+func (inputs *inputMap) active(bindings []binding) bool {
+	for _, b := range bindings {
+		for input := range inputs.pressed {
+			if b.matches(input) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+//bound reports whether event sets an input bound to the hotkey called name.
+func (inputs *inputMap) bound(name string, event inputEvent) bool {
+	for _, b := range inputs.hotkeys[name] {
+		for input := range inputs.physical(event) {
+			if b.matches(input) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (b binding) matches(input physicalInput) bool {
+	return input.kind == b.kind && input.name == b.name && input.sign == b.sign &&
+		(b.kind == inputKey || b.device == anyDevice || b.device == input.device)
+}
+
+//controller returns the buttons of the controller in port that are held and those held to autofire,
+//either through Turbo A and B or because they are set to autofire.
+func (inputs *inputMap) controller(port int) (buttons [8]bool, turbo [8]bool) {
+	if port >= len(inputs.ports) {
+		return buttons, turbo
+	}
+	for button := range buttons {
+		held := inputs.active(inputs.ports[port][button])
+		if port < len(inputs.autofire) && inputs.autofire[port][button] {
+			turbo[button] = held
+		} else {
+			buttons[button] = held
+		}
+	}
+	turbo[nes.ButtonA] = turbo[nes.ButtonA] || inputs.active(inputs.ports[port][turboA])
+	turbo[nes.ButtonB] = turbo[nes.ButtonB] || inputs.active(inputs.ports[port][turboB])
+	return buttons, turbo
+}
+
+//release returns the events that let go of everything held on a game pad, for when it is unplugged.
+func (inputs *inputMap) release(device int) []inputEvent {
+	var events []inputEvent
+	for input := range inputs.pressed {
+		if input.kind != inputKey && input.device == device {
+			events = append(events, inputEvent{kind: input.kind, device: device, name: input.name})
+		}
+	}
+	return events
+}
+
+//releaseKeys returns the events that let go of every key held on the keyboard.
+func (inputs *inputMap) releaseKeys() []inputEvent {
+	var events []inputEvent
+	for input := range inputs.pressed {
+		if input.kind == inputKey {
+			events = append(events, keyEvent(input.name, false))
+		}
+	}
+	return events
+}
+
+//bind replaces the bindings of a button with b.
+func (inputs *inputMap) bind(port int, button int, b binding) {
+	for len(inputs.ports) <= port {
+		inputs.ports = append(inputs.ports, [portInputCount][]binding{})
+	}
+	inputs.ports[port][button] = []binding{b}
+}
+
+//rebinder walks through every button of every player, binding each to the next input pressed.
+type rebinder struct {
+	inputs *inputMap
+	steps  []rebindStep
+	//held keeps an input that is still down from being bound to the next button too.
+	held map[physicalInput]bool
+}
+
+type rebindStep struct {
+	port   int
+	button int
+}
+
+func newRebinder(inputs *inputMap, players int) *rebinder {
+	r := &rebinder{inputs: inputs, held: map[physicalInput]bool{}}
+	for input := range inputs.pressed {
+		r.held[input] = true
+	}
+	for port := 0; port < players && port < len(inputs.ports); port++ {
+		for button := range portInputNames {
+			r.steps = append(r.steps, rebindStep{port: port, button: button})
+		}
+	}
+	return r
+}
+
+//prompt describes the button waiting for an input.
+func (r *rebinder) prompt() string {
+	step := r.steps[0]
+	return fmt.Sprintf("Press the input for port %d %s (Escape keeps %s)",
+		step.port+1, portInputNames[step.button], bindingList(r.inputs.ports[step.port][step.button]))
+}
+
+//handle binds the current button to whatever event newly presses and reports whether
+//every button has been bound.
+func (r *rebinder) handle(event inputEvent) bool {
+	for input, pressed := range r.inputs.physical(event) {
+		if !pressed {
+			delete(r.held, input)
+			continue
+		}
+		if r.held[input] || len(r.steps) == 0 {
+			continue
+		}
+		r.held[input] = true
+		step := r.steps[0]
+		r.steps = r.steps[1:]
+		if input.kind == inputKey && input.name == "escape" {
+			continue
+		}
+		b := binding{kind: input.kind, device: input.device, name: input.name, sign: input.sign}
+		r.inputs.bind(step.port, step.button, b)
+	}
+	return len(r.steps) == 0
+}
+
+//bindingList formats bindings for messages.
+func bindingList(bindings []binding) string {
+	if len(bindings) == 0 {
+		return "nothing"
+	}
+	var texts []string
+	for _, b := range bindings {
+		texts = append(texts, b.String())
+	}
+	return strings.Join(texts, ", ")
+}
+
+//padLayout is how a game pad labels its face buttons.
+type padLayout int
+
+const (
+	//padXbox pads have A at the bottom and B on the right, the names bindings use.
+	padXbox padLayout = iota
+	//padNintendo pads have A on the right and B at the bottom, and X and Y swapped too.
+	padNintendo
+)
+
+//padButtonNames translates the labels SDL reports for a layout to the Xbox labels at the same
+//positions, so the defaults and a config put each button in the same place on every pad.
+var padButtonNames = map[padLayout]map[string]string{
+	padNintendo: {"a": "b", "b": "a", "x": "y", "y": "x"},
+}
+
+//padButtonName returns the name bindings use for the button SDL labels label on a pad with layout.
+func padButtonName(layout padLayout, label string) string {
+	if name, ok := padButtonNames[layout][label]; ok {
+		return name
+	}
+	return label
+}
+
+//padSlots numbers game pads in the order they were plugged in, reusing the numbers of unplugged
+//pads, so "pad0" in a binding keeps meaning the same pad for as long as it stays connected.
+type padSlots struct {
+	ids       []int
+	connected []bool
+}
+
+//connect returns the device number for a newly plugged in pad with the given instance id.
+func (slots *padSlots) connect(id int) int {
+	if device, ok := slots.device(id); ok {
+		return device
+	}
+	for device, connected := range slots.connected {
+		if !connected {
+			slots.ids[device], slots.connected[device] = id, true
+			return device
+		}
+	}
+	slots.ids = append(slots.ids, id)
+	slots.connected = append(slots.connected, true)
+	return len(slots.ids) - 1
+}
+
+//device returns the device number of a connected pad.
+func (slots *padSlots) device(id int) (int, bool) {
+	for device, slotID := range slots.ids {
+		if slots.connected[device] && slotID == id {
+			return device, true
+		}
+	}
+	return 0, false
+}
+
+//disconnect frees the number of an unplugged pad and returns it.
+func (slots *padSlots) disconnect(id int) (int, bool) {
+	device, ok := slots.device(id)
+	if ok {
+		slots.connected[device] = false
+	}
+	return device, ok
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"log"
+
+	"github.com/jedgentry/NesGo/nes"
+)
+
+//familyKeys maps SDL key names to the Family BASIC keys in the same place on a US keyboard.
+//The keys a US keyboard lacks take the spare keys around them.
+var familyKeys = map[string]int{
+	"a": nes.KeyA, "b": nes.KeyB, "c": nes.KeyC, "d": nes.KeyD, "e": nes.KeyE, "f": nes.KeyF,
+	"g": nes.KeyG, "h": nes.KeyH, "i": nes.KeyI, "j": nes.KeyJ, "k": nes.KeyK, "l": nes.KeyL,
+	"m": nes.KeyM, "n": nes.KeyN, "o": nes.KeyO, "p": nes.KeyP, "q": nes.KeyQ, "r": nes.KeyR,
+	"s": nes.KeyS, "t": nes.KeyT, "u": nes.KeyU, "v": nes.KeyV, "w": nes.KeyW, "x": nes.KeyX,
+	"y": nes.KeyY, "z": nes.KeyZ,
+	"0": nes.Key0, "1": nes.Key1, "2": nes.Key2, "3": nes.Key3, "4": nes.Key4,
+	"5": nes.Key5, "6": nes.Key6, "7": nes.Key7, "8": nes.Key8, "9": nes.Key9,
+	"f1": nes.KeyF1, "f2": nes.KeyF2, "f3": nes.KeyF3, "f4": nes.KeyF4,
+	"f5": nes.KeyF5, "f6": nes.KeyF6, "f7": nes.KeyF7, "f8": nes.KeyF8,
+	"-": nes.KeyMinus, "=": nes.KeyCaret, "`": nes.KeyYen,
+	"[": nes.KeyAt, "]": nes.KeyLeftBracket, "\\": nes.KeyRightBracket,
+	";": nes.KeySemicolon, "'": nes.KeyColon,
+	",": nes.KeyComma, ".": nes.KeyPeriod, "/": nes.KeySlash,
+	"return": nes.KeyReturn, "space": nes.KeySpace, "escape": nes.KeyEscape,
+	"left shift": nes.KeyLeftShift, "right shift": nes.KeyRightShift,
+	"left ctrl": nes.KeyCtrl, "left alt": nes.KeyGraph,
+	"right alt": nes.KeyUnderscore, "right ctrl": nes.KeyKana,
+	"end": nes.KeyStop, "home": nes.KeyClearHome,
+	"insert": nes.KeyInsert, "delete": nes.KeyDelete, "backspace": nes.KeyDelete,
+	"up": nes.KeyUp, "down": nes.KeyDown, "left": nes.KeyLeft, "right": nes.KeyRight,
+}
+
+var familyKeyboard *nes.FamilyKeyboard
+
+//typing is set while the host keyboard types on the Family BASIC keyboard instead of
+//working the controllers and hotkeys.
+var typing bool
+
+//typeKey sends a key event to the Family BASIC keyboard while typing and reports whether it took it.
+//The keyboard hotkey is left to the input map, so typing can be turned off again.
+func typeKey(event inputEvent) bool {
+	if !typing || inputs.bound(hotkeyKeyboard, event) {
+		return false
+	}
+	if key, ok := familyKeys[event.name]; ok {
+		familyKeyboard.SetKey(key, event.value > 0.5)
+	}
+	return true
+}
+
+//toggleTyping switches the host keyboard between the Family BASIC keyboard and the input map.
+//Whatever the keys held on the side being left is let go of.
+func toggleTyping() {
+	if familyKeyboard == nil {
+		log.Println("No Family BASIC keyboard is plugged in, see -expansion")
+		return
+	}
+	typing = !typing
+	if !typing {
+		familyKeyboard.ReleaseKeys()
+		log.Println("The keyboard works the controllers again")
+		return
+	}
+	for _, release := range inputs.releaseKeys() {
+		// The hotkeys let go of here are dropped rather than run, and the held ones stopped below.
+		inputs.handle(release)
+	}
+	rewinding, fastForward = false, false
+	log.Println("Typing on the Family BASIC keyboard")
+}
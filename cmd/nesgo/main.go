@@ -0,0 +1,226 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"github.com/jedgentry/NesGo/nes"
+	"github.com/veandco/go-sdl2/sdl"
+)
+
+func check(e error) {
+	if e != nil {
+		panic(e)
+	}
+}
+
+var window *sdl.Window
+var windowRenderer *sdl.Renderer
+var windowTexture *sdl.Texture
+var buffer [w * h * 4]byte
+var debugSurface *sdl.Surface
+var debugRenderer *sdl.Renderer
+var debugTexture *sdl.Texture
+
+var system *nes.System
+var debug int
+var framesRendered int
+var fpsTimer time.Time
+
+const debugNumScreens = 2
+const scale = 2
+const w = nes.Width
+const h = nes.Height
+
+var paused bool
+var romPath string
+var stateSlot = 1
+
+func sdlInit() {
+	var err error
+	sdl.Init(sdl.INIT_EVERYTHING)
+
+	window, windowRenderer, err = sdl.CreateWindowAndRenderer(w*scale, h*scale, 0)
+	check(err)
+	windowTexture, err = windowRenderer.CreateTexture(sdl.PIXELFORMAT_ARGB8888, sdl.TEXTUREACCESS_STREAMING, w, h)
+	check(err)
+
+	debugSurface, err = sdl.CreateRGBSurface(0, w*scale, h*scale, 32, 0x00ff0000, 0x0000ff00, 0x000000ff, 0xff000000)
+	check(err)
+	debugRenderer, err = sdl.CreateSoftwareRenderer(debugSurface)
+	check(err)
+	debugTexture, err = windowRenderer.CreateTexture(sdl.PIXELFORMAT_ARGB8888, sdl.TEXTUREACCESS_STREAMING, w*scale, h*scale)
+	debugTexture.SetBlendMode(sdl.BLENDMODE_BLEND)
+
+	debugRenderer.SetScale(scale, scale)
+	fpsTimer = time.Now()
+}
+
+func sdlLoop() {
+	var event sdl.Event
+	running := true
+	for running {
+		frameStart := time.Now()
+
+		for event = sdl.PollEvent(); event != nil; event = sdl.PollEvent() {
+			switch t := event.(type) {
+			case *sdl.QuitEvent:
+				running = false
+			case *sdl.KeyboardEvent:
+				if typing {
+					if event, ok := keyboardInput(t); !ok || typeKey(event) {
+						break
+					}
+				}
+				if mixerKey(t) {
+					break
+				}
+				if event, ok := keyboardInput(t); ok {
+					handleInput(event)
+				}
+			case *sdl.ControllerDeviceEvent, *sdl.ControllerButtonEvent, *sdl.ControllerAxisEvent:
+				controllerInput(event)
+			case *sdl.MouseMotionEvent, *sdl.MouseButtonEvent, *sdl.WindowEvent:
+				mouseInput(event)
+			}
+		}
+
+		if rewinding {
+			if rewindFrame() {
+				pushFrame()
+			}
+		} else if !paused && rebinding == nil {
+			frames := 1
+			if fastForward {
+				frames = *fastForwardSpeed
+			}
+			for i := 0; i < frames; i++ {
+				for port := 0; port < 4; port++ {
+					buttons, turbo := inputs.controller(port)
+					system.SetButtons(port, buttons)
+					system.SetTurbo(port, turbo)
+				}
+				if !movieStepFrame() {
+					stepFrame()
+				}
+			}
+			pushFrame()
+			autosaveBattery()
+		}
+
+		frameTime := time.Now().Sub(frameStart)
+		delay := (16666667 - frameTime.Nanoseconds()) / 1000000
+		if delay > 0 && !fastForward {
+			sdl.Delay(uint32(delay))
+		}
+
+		framesRendered++
+	}
+}
+
+func pushPixels() {
+	for i, col := range system.Framebuffer() {
+		buffer[i*4+0] = byte((col >> 0) & 0xFF)
+		buffer[i*4+1] = byte((col >> 8) & 0xFF)
+		buffer[i*4+2] = byte((col >> 16) & 0xFF)
+		buffer[i*4+3] = byte((col >> 24) & 0xFF)
+	}
+}
+
+func pushFrame() {
+	pushPixels()
+	windowTexture.Update(nil, buffer[:], 4*w)
+	windowRenderer.Copy(windowTexture, nil, nil)
+	windowRenderer.Present()
+}
+
+//statePath returns the file for a numbered save state slot, next to the ROM.
+func statePath(slot int) string {
+	return fmt.Sprintf("%s.state%d", romPath, slot)
+}
+
+func saveStateSlot(slot int) {
+	file, err := os.Create(statePath(slot))
+	if err != nil {
+		log.Println(err)
+		return
+	}
+	defer file.Close()
+	if err := system.SaveState(file); err != nil {
+		log.Println(err)
+		return
+	}
+	log.Printf("Saved state to slot %d", slot)
+}
+
+func loadStateSlot(slot int) {
+	if movieActive() {
+		log.Println("Can't load a state during a movie")
+		return
+	}
+	file, err := os.Open(statePath(slot))
+	if err != nil {
+		log.Println(err)
+		return
+	}
+	defer file.Close()
+	if err := system.LoadState(file); err != nil {
+		log.Println(err)
+		return
+	}
+	if rewinder != nil {
+		rewinder.Reset()
+	}
+	log.Printf("Loaded state from slot %d", slot)
+}
+
+func sdlCleanup() {
+	window.Destroy()
+	sdl.Quit()
+}
+
+func startWithRom() {
+	file, err := os.Open(romPath)
+	check(err)
+	defer file.Close()
+
+	system = nes.New()
+	check(system.LoadROM(file))
+	loadBattery()
+	devicesInit()
+	traceInit()
+	defer traceCleanup()
+	movieInit()
+	filterInit()
+	captureInit()
+	if *headless {
+		runHeadless()
+		captureStop()
+		return
+	}
+	rewindInit()
+	inputInit()
+
+	sdlInit()
+	mouseInit()
+	audioInit()
+	sdlLoop()
+	captureStop()
+	audioCleanup()
+	saveBattery()
+	movieCleanup()
+	controllerCleanup()
+	sdlCleanup()
+}
+
+func main() {
+	flag.Parse()
+	romPath = "roms/Kirby's Adventure (E).nes"
+	if flag.NArg() > 0 {
+		romPath = flag.Arg(0)
+	}
+	startWithRom()
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"log"
+
+	"github.com/jedgentry/NesGo/nes"
+	"github.com/veandco/go-sdl2/sdl"
+)
+
+//mixerChannel is the channel the volume keys apply to, the last one muted or soloed.
+var mixerChannel = nes.ChannelPulse1
+
+const volumeStep = 0.1
+
+//mixerKey handles the mixer hotkeys and reports whether the event was one of them.
+//Ctrl+1-6 mutes a channel and Alt+1-6 solos it, in ChannelNames order. Ctrl+minus and
+//Ctrl+equals turn the last picked channel down and up, and Ctrl+0 resets the mixer.
+func mixerKey(event *sdl.KeyboardEvent) bool {
+	ctrl := event.Keysym.Mod&sdl.KMOD_CTRL != 0
+	alt := event.Keysym.Mod&sdl.KMOD_ALT != 0
+	if !ctrl && !alt {
+		return false
+	}
+	pressed := event.Type == sdl.KEYDOWN
+	mixer := system.Mixer()
+	scancode := event.Keysym.Scancode
+
+	if scancode >= sdl.SCANCODE_1 && int(scancode-sdl.SCANCODE_1) < len(nes.ChannelNames) {
+		if pressed {
+			mixerChannel = int(scancode - sdl.SCANCODE_1)
+			if ctrl {
+				mixer.SetMuted(mixerChannel, !mixer.Muted(mixerChannel))
+			} else {
+				mixer.SetSolo(mixerChannel, !mixer.Solo(mixerChannel))
+			}
+			logMixer(mixer, mixerChannel)
+		}
+		return true
+	}
+	if !ctrl {
+		return false
+	}
+	switch scancode {
+	case sdl.SCANCODE_MINUS, sdl.SCANCODE_EQUALS:
+		if pressed {
+			volume := mixer.Volume(mixerChannel)
+			if scancode == sdl.SCANCODE_MINUS {
+				volume -= volumeStep
+			} else {
+				volume += volumeStep
+			}
+			mixer.SetVolume(mixerChannel, volume)
+			logMixer(mixer, mixerChannel)
+		}
+		return true
+	case sdl.SCANCODE_0:
+		if pressed {
+			mixer.Reset()
+			log.Println("Mixer reset")
+		}
+		return true
+	}
+	return false
+}
+
+func logMixer(mixer *nes.Mixer, channel int) {
+	log.Printf("%s: volume %.1f muted %v solo %v", nes.ChannelNames[channel],
+		mixer.Volume(channel), mixer.Muted(channel), mixer.Solo(channel))
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"crypto/sha1"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/jedgentry/NesGo/nes"
+)
+
+var recordPath = flag.String("record", "", "record input to a movie file (.fm2 or NesGo binary)")
+var recordFromState = flag.Bool("record-from-state", false, "start the recording from the current state instead of power on")
+var playPath = flag.String("play", "", "play back a movie file (.fm2 or NesGo binary)")
+var headless = flag.Bool("headless", false, "run without a window; requires -play")
+var frameLimit = flag.Int("frames", 0, "stop playback after this many frames (0 plays the whole movie)")
+
+var recorder *nes.MovieRecorder
+var player *nes.MoviePlayer
+
+func isFM2(path string) bool {
+	return strings.EqualFold(filepath.Ext(path), ".fm2")
+}
+
+func readMovie(path string) (*nes.Movie, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	if isFM2(path) {
+		return nes.ReadFM2(file)
+	}
+	return nes.ReadMovie(file)
+}
+
+func writeMovie(path string, movie *nes.Movie) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	if isFM2(path) {
+		return nes.WriteFM2(file, movie)
+	}
+	return nes.WriteMovie(file, movie)
+}
+
+func movieInit() {
+	var err error
+	if *playPath != "" {
+		movie, err := readMovie(*playPath)
+		check(err)
+		player, err = nes.PlayMovie(system, movie)
+		if errors.Is(err, nes.ErrMovieDevices) {
+			err = fmt.Errorf("%v: it needs %q in port 1, port 2 and the expansion port", err, movie.Devices)
+		}
+		check(err)
+	} else if *recordPath != "" {
+		recorder, err = nes.RecordMovie(system, !*recordFromState)
+		check(err)
+	}
+}
+
+//movieActive reports whether a movie is being played or recorded. Loading states and rewinding
+//are refused then, as they would take the console away from the recorded input, and battery
+//saves aren't written since the movie started from blank RAM.
+func movieActive() bool {
+	return recorder != nil || player != nil
+}
+
+//movieStepFrame emulates a frame from the movie being played or recorded.
+//It reports false when there is no movie or playback has finished.
+func movieStepFrame() bool {
+	switch {
+	case player != nil:
+		if *frameLimit > 0 && player.Frame() >= *frameLimit {
+			return false
+		}
+		more, err := player.StepFrame()
+		check(err)
+		return more
+	case recorder != nil:
+		_, err := recorder.StepFrame()
+		check(err)
+		return true
+	}
+	return false
+}
+
+func movieCleanup() {
+	if recorder != nil {
+		check(writeMovie(*recordPath, recorder.Movie()))
+	}
+}
+
+//runHeadless plays the movie without a window and prints a hash of the final picture,
+//which regression scripts can compare against a known good run.
+func runHeadless() {
+	if player == nil {
+		check(fmt.Errorf("-headless requires -play"))
+	}
+	frames, err := player.Run(*frameLimit)
+	check(err)
+	hash := sha1.New()
+	for _, col := range system.Framebuffer() {
+		hash.Write([]byte{byte(col >> 16), byte(col >> 8), byte(col)})
+	}
+	fmt.Printf("%d frames %x\n", frames, hash.Sum(nil))
+}
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"flag"
+
+	"github.com/jedgentry/NesGo/nes"
+)
+
+var rewindInterval = flag.Int("rewind-interval", 2, "frames between rewind snapshots")
+var rewindSpeed = flag.Int("rewind-speed", 1, "snapshots stepped back per frame while rewinding")
+var rewindBudget = flag.Int("rewind-budget", 64, "memory for rewind history in megabytes (0 disables)")
+
+var rewinder *nes.Rewinder
+var rewinding bool
+
+func rewindInit() {
+	if *rewindBudget > 0 {
+		rewinder = nes.NewRewinder(system, *rewindInterval, *rewindBudget<<20)
+	}
+}
+
+//stepFrame emulates one frame, recording rewind history if it is enabled.
+func stepFrame() {
+	if rewinder != nil {
+		rewinder.StepFrame()
+	} else {
+		system.StepFrame()
+	}
+}
+
+//rewindFrame steps back through the history and reports whether anything was left.
+func rewindFrame() bool {
+	return rewinder != nil && rewinder.Rewind(*rewindSpeed)
+}
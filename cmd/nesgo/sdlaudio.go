@@ -0,0 +1,60 @@
+package main
+
+import (
+	"encoding/binary"
+	"math"
+
+	"github.com/veandco/go-sdl2/sdl"
+)
+
+//SDLAudio plays samples through an SDL audio device using its queue.
+type SDLAudio struct {
+	device     sdl.AudioDeviceID
+	sampleRate int
+	maxQueued  uint32
+	data       []byte
+}
+
+func NewSDLAudio(sampleRate int) (*SDLAudio, error) {
+	desired := sdl.AudioSpec{
+		Freq:     int32(sampleRate),
+		Format:   sdl.AUDIO_F32LSB,
+		Channels: 1,
+		Samples:  1024,
+	}
+	var obtained sdl.AudioSpec
+	device, err := sdl.OpenAudioDevice("", false, &desired, &obtained, 0)
+	if err != nil {
+		return nil, err
+	}
+	sdl.PauseAudioDevice(device, false)
+	return &SDLAudio{
+		device:     device,
+		sampleRate: sampleRate,
+		maxQueued:  uint32(float64(sampleRate)*audioLatency) * 4,
+	}, nil
+}
+
+func (a *SDLAudio) SampleRate() int {
+	return a.sampleRate
+}
+
+//WriteSamples queues samples for playback, dropping them if the queue is already full.
+func (a *SDLAudio) WriteSamples(samples []float32) {
+	if sdl.GetQueuedAudioSize(a.device) > a.maxQueued {
+		return
+	}
+	if cap(a.data) < len(samples)*4 {
+		a.data = make([]byte, len(samples)*4)
+	}
+	data := a.data[:len(samples)*4]
+	for i, sample := range samples {
+		binary.LittleEndian.PutUint32(data[i*4:], math.Float32bits(sample))
+	}
+	sdl.QueueAudio(a.device, data)
+}
+
+func (a *SDLAudio) Close() error {
+	sdl.CloseAudioDevice(a.device)
+	return nil
+}
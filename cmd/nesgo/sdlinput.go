@@ -0,0 +1,203 @@
+package main
+
+import (
+	"flag"
+	"log"
+	"os"
+	"path/filepath"
+
+	"github.com/jedgentry/NesGo/nes"
+	"github.com/veandco/go-sdl2/sdl"
+)
+
+var inputPath = flag.String("input", "", "input config file (default nesgo/input.json in the user config directory)")
+var fastForwardSpeed = flag.Int("fast-forward", 4, "frames emulated per frame shown while fast forwarding")
+
+var inputs *inputMap
+var rebinding *rebinder
+var fastForward bool
+var pads padSlots
+var controllers = map[sdl.JoystickID]*sdl.GameController{}
+
+//padLayouts are the SDL controller types whose face buttons aren't labelled like an Xbox pad's.
+var padLayouts = map[sdl.GameControllerType]padLayout{
+	sdl.CONTROLLER_TYPE_NINTENDO_SWITCH_PRO: padNintendo,
+}
+
+func inputConfigPath() string {
+	if *inputPath != "" {
+		return *inputPath
+	}
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "nesgo-input.json"
+	}
+	return filepath.Join(dir, "nesgo", "input.json")
+}
+
+func inputInit() {
+	config, err := readInputConfig(inputConfigPath())
+	check(err)
+	inputs, err = newInputMap(config)
+	check(err)
+	for port := 0; port < 4; port++ {
+		system.SetTurboRate(port, inputs.turboRate)
+	}
+}
+
+//keyboardInput turns an SDL key event into an input event. Key repeats are dropped.
+func keyboardInput(event *sdl.KeyboardEvent) (inputEvent, bool) {
+	if event.Repeat != 0 {
+		return inputEvent{}, false
+	}
+	return keyEvent(sdl.GetScancodeName(event.Keysym.Scancode), event.Type == sdl.KEYDOWN), true
+}
+
+//controllerInput handles game pads being plugged in and out and turns their buttons and axes into input events.
+func controllerInput(event sdl.Event) {
+	switch t := event.(type) {
+	case *sdl.ControllerDeviceEvent:
+		switch t.Type {
+		case sdl.CONTROLLERDEVICEADDED:
+			// Which is the device index here, an instance id everywhere else.
+			controller := sdl.GameControllerOpen(int(t.Which))
+			if controller == nil {
+				log.Printf("Can't open game pad %d: %v", t.Which, sdl.GetError())
+				return
+			}
+			id := controller.Joystick().InstanceID()
+			if _, open := controllers[id]; open {
+				controller.Close()
+				return
+			}
+			controllers[id] = controller
+			log.Printf("Game pad %d connected: %s", pads.connect(int(id)), controller.Name())
+		case sdl.CONTROLLERDEVICEREMOVED:
+			device, ok := pads.disconnect(int(t.Which))
+			if !ok {
+				return
+			}
+			for _, release := range inputs.release(device) {
+				handleInput(release)
+			}
+			controllers[t.Which].Close()
+			delete(controllers, t.Which)
+			log.Printf("Game pad %d disconnected", device)
+		}
+	case *sdl.ControllerButtonEvent:
+		if device, ok := pads.device(int(t.Which)); ok {
+			label := sdl.GameControllerGetStringForButton(sdl.GameControllerButton(t.Button))
+			name := padButtonName(padLayouts[controllers[t.Which].Type()], label)
+			event := inputEvent{kind: inputPadButton, device: device, name: name}
+			if t.State == sdl.PRESSED {
+				event.value = 1
+			}
+			handleInput(event)
+		}
+	case *sdl.ControllerAxisEvent:
+		if device, ok := pads.device(int(t.Which)); ok {
+			name := sdl.GameControllerGetStringForAxis(sdl.GameControllerAxis(t.Axis))
+			handleInput(inputEvent{kind: inputPadAxis, device: device, name: name, value: float64(t.Value) / 32767})
+		}
+	}
+}
+
+func controllerCleanup() {
+	for id, controller := range controllers {
+		controller.Close()
+		delete(controllers, id)
+	}
+}
+
+//handleInput feeds an event to the rebinding prompt if one is open, otherwise to the input map.
+func handleInput(event inputEvent) {
+	hotkeys := inputs.handle(event)
+	if rebinding != nil {
+		// Inputs are being bound rather than used, so only let go of the hotkeys that act while held.
+		rewinding, fastForward = false, false
+		remaining := len(rebinding.steps)
+		if rebinding.handle(event) {
+			rebinding = nil
+			saveInputConfig()
+		} else if len(rebinding.steps) != remaining {
+			log.Println(rebinding.prompt())
+		}
+		return
+	}
+	for _, hotkey := range hotkeys {
+		runHotkey(hotkey)
+	}
+}
+
+func saveInputConfig() {
+	path := inputConfigPath()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		log.Println(err)
+		return
+	}
+	if err := writeInputConfig(path, inputs.config()); err != nil {
+		log.Println(err)
+		return
+	}
+	log.Printf("Saved input config to %s", path)
+}
+
+//runHotkey acts on a hotkey. Held hotkeys follow the key, the others act when it is released.
+func runHotkey(hotkey hotkeyEvent) {
+	switch hotkey.name {
+	case hotkeyRewind:
+		rewinding = hotkey.pressed && !movieActive()
+	case hotkeyFastForward:
+		fastForward = hotkey.pressed
+	}
+	if hotkey.pressed {
+		return
+	}
+	switch hotkey.name {
+	case hotkeyPause:
+		paused = !paused
+	case hotkeyReset:
+		resetSystem()
+	case hotkeySaveState:
+		saveStateSlot(stateSlot)
+	case hotkeyLoadState:
+		loadStateSlot(stateSlot)
+	case hotkeyDebug:
+		debug = (debug + 1) % (debugNumScreens + 1)
+	case hotkeyCapture:
+		toggleCapture()
+	case hotkeyKeyboard:
+		toggleTyping()
+	case hotkeyRebind:
+		players := 2
+		if system.Multitap() != nes.MultitapNone {
+			players = 4
+		}
+		rebinding = newRebinder(inputs, players)
+		log.Println(rebinding.prompt())
+	default:
+		if slot, ok := hotkeySlotNumber(hotkey.name); ok {
+			stateSlot = slot
+			log.Printf("Save state slot %d", stateSlot)
+		}
+	}
+}
+
+//resetSystem presses the reset button, going through the recorder so the reset is in the movie.
+func resetSystem() {
+	switch {
+	case player != nil:
+		log.Println("Can't reset while playing a movie")
+		return
+	case recorder != nil:
+		recorder.Reset()
+	default:
+		if err := system.ResetSystem(); err != nil {
+			log.Println(err)
+			return
+		}
+	}
+	if rewinder != nil {
+		rewinder.Reset()
+	}
+}
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"log"
+	"os"
+)
+
+var tracePath = flag.String("trace", "", "write every instruction the CPU runs, with the registers, to a file")
+
+var traceFile *os.File
+var traceWriter *bufio.Writer
+
+func traceInit() {
+	if *tracePath == "" {
+		return
+	}
+	var err error
+	traceFile, err = os.Create(*tracePath)
+	check(err)
+	traceWriter = bufio.NewWriter(traceFile)
+	system.SetTrace(traceWriter)
+}
+
+func traceCleanup() {
+	if traceFile == nil {
+		return
+	}
+	system.SetTrace(nil)
+	if err := traceWriter.Flush(); err != nil {
+		log.Println(err)
+	}
+	if err := traceFile.Close(); err != nil {
+		log.Println(err)
+	}
+	traceFile = nil
+}
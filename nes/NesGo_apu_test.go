@@ -0,0 +1,204 @@
+package nes
+
+import (
+	"os"
+	"strings"
+	"testing"
+)
+
+//spinProgram masks interrupts and spins, leaving the APU alone.
+var spinProgram = []byte{
+	0x78,             // SEI
+	0x4C, 0x01, 0xE0, // JMP $E001
+}
+
+func TestAPULengthStatus(t *testing.T) {
+	system := newTestSystem(t, buildTestROM(0, 2, 1, spinProgram))
+	memory := &system.memory
+
+	//A disabled channel ignores length loads.
+	memory.WriteByte(0x4003, 0x08)
+	if status := memory.ReadByte(0x4015); status&0x1F != 0 {
+		t.Errorf("status %#02x after loading a disabled channel, want no lengths", status)
+	}
+
+	//Length index 3 is 2 half frames and index 1 is 254, so only pulse 2 survives a few frames.
+	memory.WriteByte(0x4015, 0x0F)
+	memory.WriteByte(0x4003, 0x18)
+	memory.WriteByte(0x4007, 0x08)
+	memory.WriteByte(0x400B, 0x18)
+	memory.WriteByte(0x400F, 0x18)
+	if status := memory.ReadByte(0x4015); status&0x1F != 0x0F {
+		t.Errorf("status %#02x after loading every channel, want 0x0F", status)
+	}
+	for i := 0; i < 8; i++ {
+		system.StepFrame()
+	}
+	if status := memory.ReadByte(0x4015); status&0x1F != 0x02 {
+		t.Errorf("status %#02x after 8 frames, want only pulse 2 (0x02)", status)
+	}
+
+	memory.WriteByte(0x4015, 0x00)
+	if status := memory.ReadByte(0x4015); status&0x1F != 0 {
+		t.Errorf("status %#02x after disabling every channel, want 0", status)
+	}
+}
+
+func TestAPUFrameIRQ(t *testing.T) {
+	//Taking the IRQ pushes the return address and status, which is how we spot it.
+	program := []byte{
+		0x58,             // CLI
+		0x4C, 0x00, 0xE0, // JMP $E000
+	}
+	system := newTestSystem(t, buildTestROM(0, 2, 1, program))
+	sp := system.cpu.sp
+	for system.cpu.sp == sp && system.cpu.totalCycles < frameLength4*2 {
+		system.Emulate()
+	}
+	if system.cpu.sp == sp {
+		t.Fatal("frame IRQ never reached the CPU")
+	}
+	//The flag is raised on cycle 29828 and the CPU finishes its instruction before taking it.
+	if cycles := system.cpu.totalCycles; cycles < frameIRQStart || cycles > frameIRQStart+16 {
+		t.Errorf("IRQ taken after %d cycles, want about %d", cycles, frameIRQStart)
+	}
+
+	system = newTestSystem(t, buildTestROM(0, 2, 1, spinProgram))
+	system.memory.WriteByte(0x4017, 0x40)
+	system.StepFrame()
+	system.StepFrame()
+	if status := system.memory.ReadByte(0x4015); status&0x40 != 0 {
+		t.Errorf("status %#02x with the frame IRQ inhibited, want bit 6 clear", status)
+	}
+
+	system.memory.WriteByte(0x4017, 0x00)
+	system.StepFrame()
+	system.StepFrame()
+	if status := system.memory.ReadByte(0x4015); status&0x40 == 0 {
+		t.Errorf("status %#02x after two frames, want the frame IRQ flag", status)
+	}
+	if status := system.memory.ReadByte(0x4015); status&0x40 != 0 {
+		t.Errorf("status %#02x, want reading $4015 to clear the frame IRQ flag", status)
+	}
+}
+
+func TestAPUDMCIRQ(t *testing.T) {
+	system := newTestSystem(t, buildTestROM(0, 2, 1, spinProgram))
+	memory := &system.memory
+	memory.WriteByte(0x4017, 0x40)
+	memory.WriteByte(0x4010, 0x8F) // IRQ on, fastest rate
+	memory.WriteByte(0x4012, 0x00)
+	memory.WriteByte(0x4013, 0x00) // One byte sample
+	memory.WriteByte(0x4015, 0x10)
+	system.StepFrame()
+
+	status := memory.ReadByte(0x4015)
+	if status&0x80 == 0 {
+		t.Fatalf("status %#02x after the sample ended, want the DMC IRQ flag", status)
+	}
+	if status&0x10 != 0 {
+		t.Errorf("status %#02x, want the DMC to have no bytes left", status)
+	}
+	if !system.apu.irqPending() {
+		t.Error("DMC IRQ flag set but the IRQ line is not asserted")
+	}
+	memory.WriteByte(0x4015, 0x00)
+	if status := memory.ReadByte(0x4015); status&0x80 != 0 {
+		t.Errorf("status %#02x, want writing $4015 to clear the DMC IRQ flag", status)
+	}
+}
+
+//blarggResult runs a test ROM that reports through PRG-RAM at $6000 the way blargg's do, and returns its status and text.
+func blarggResult(t *testing.T, rom []byte) (byte, string) {
+	system := newTestSystem(t, rom)
+	memory := &system.memory
+	signature := false
+	for frame := 0; frame < 60*30; frame++ {
+		system.StepFrame()
+		signature = memory.ReadByte(0x6001) == 0xDE && memory.ReadByte(0x6002) == 0xB0 && memory.ReadByte(0x6003) == 0x61
+		if signature && memory.ReadByte(0x6000) < 0x80 {
+			break
+		}
+	}
+	if !signature {
+		t.Fatal("the test never wrote its signature to $6001")
+	}
+
+	var text strings.Builder
+	for address := uint16(0x6004); address < 0x7000; address++ {
+		c := memory.ReadByte(address)
+		if c == 0 {
+			break
+		}
+		text.WriteByte(c)
+	}
+	return memory.ReadByte(0x6000), strings.TrimSpace(text.String())
+}
+
+func TestBlarggAPU(t *testing.T) {
+	roms := []string{
+		"1-len_ctr",
+		"2-len_table",
+		"3-irq_flag",
+		"4-jitter",
+		"5-len_timing",
+		"6-irq_flag_timing",
+		"7-dmc_basics",
+		"8-dmc_rates",
+	}
+	for _, rom := range roms {
+		t.Run(rom, func(t *testing.T) {
+			path := "../test-roms/apu_test/rom_singles/" + rom + ".nes"
+			data, err := os.ReadFile(path)
+			if err != nil {
+				t.Skipf("%s not available: %v", path, err)
+			}
+			status, text := blarggResult(t, data)
+			if status != 0 {
+				t.Errorf("status %#02x: %s", status, text)
+			}
+		})
+	}
+}
+
+//apuSelfTest reports like blargg's ROMs do: $80 in $6000 while it runs, the signature in
+//$6001-$6003, then the result in $6000. It loads pulse 1's length counter, with the channel
+//enabled by enable, and checks that $4015 shows it. The result is looked up in a table at
+//$E100 indexed by the status byte rather than branched on: 0 if bit 0 is set, 2 if not.
+func apuSelfTest(enable byte) []byte {
+	program := make([]byte, 0x200)
+	copy(program, []byte{
+		0x78,       // SEI
+		0xA0, 0x80, // LDY #$80
+		0x8C, 0x00, 0x60, // STY $6000 (running)
+		0xA0, 0xDE, // LDY #$DE
+		0x8C, 0x01, 0x60, // STY $6001
+		0xA0, 0xB0, // LDY #$B0
+		0x8C, 0x02, 0x60, // STY $6002
+		0xA0, 0x61, // LDY #$61
+		0x8C, 0x03, 0x60, // STY $6003
+		0xA0, enable, // LDY #enable
+		0x8C, 0x15, 0x40, // STY $4015
+		0xA0, 0x18, // LDY #$18
+		0x8C, 0x03, 0x40, // STY $4003 (load pulse 1's length)
+		0xAE, 0x15, 0x40, // LDX $4015
+		0xBC, 0x00, 0xE1, // LDY $E100,X
+		0x8C, 0x00, 0x60, // STY $6000
+		0x4C, 0x28, 0xE0, // JMP $E028
+	})
+	for status := 0; status < 0x100; status++ {
+		if status&0x01 == 0 {
+			program[0x100+status] = 2
+		}
+	}
+	return program
+}
+
+func TestBlarggProtocol(t *testing.T) {
+	if status, text := blarggResult(t, buildTestROM(0, 2, 1, apuSelfTest(0x01))); status != 0 {
+		t.Errorf("status %#02x: %s", status, text)
+	}
+	if status, _ := blarggResult(t, buildTestROM(0, 2, 1, apuSelfTest(0x00))); status != 2 {
+		t.Errorf("status %#02x with pulse 1 disabled, want 0x02", status)
+	}
+}
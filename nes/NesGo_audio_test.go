@@ -0,0 +1,375 @@
+package nes
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/gob"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+//squareWave feeds the resampler a square wave of the given frequency and returns the output.
+func squareWave(resampler *Resampler, frequency float64, seconds float64) []float32 {
+	clocks := int(cpuClockRate * seconds)
+	halfPeriod := cpuClockRate / frequency / 2
+	for clock := 0; clock < clocks; clock++ {
+		level := float32(0)
+		if int(float64(clock)/halfPeriod)%2 == 1 {
+			level = 1
+		}
+		resampler.Clock(level)
+	}
+	out := make([]float32, resampler.Available())
+	return out[:resampler.Read(out)]
+}
+
+//acPower returns the mean square of samples around their mean, skipping the kernel's start up.
+func acPower(samples []float32) float64 {
+	samples = samples[resamplerTaps:]
+	mean := 0.0
+	for _, sample := range samples {
+		mean += float64(sample)
+	}
+	mean /= float64(len(samples))
+	power := 0.0
+	for _, sample := range samples {
+		power += (float64(sample) - mean) * (float64(sample) - mean)
+	}
+	return power / float64(len(samples))
+}
+
+func TestResamplerStep(t *testing.T) {
+	resampler := NewResampler(cpuClockRate, 44100)
+	for clock := 0; clock < cpuClockRate/10; clock++ {
+		resampler.Clock(0.75)
+	}
+	out := make([]float32, 10000)
+	count := resampler.Read(out)
+	if count < 4409 || count > 4410 {
+		t.Errorf("got %d samples for 100ms, want 4410", count)
+	}
+	if last := out[count-1]; math.Abs(float64(last)-0.75) > 1e-6 {
+		t.Errorf("step settled at %v, want 0.75", last)
+	}
+}
+
+func TestResamplerAliasing(t *testing.T) {
+	//A 1kHz square wave is well inside the pass band and keeps its full swing.
+	low := acPower(squareWave(NewResampler(cpuClockRate, 44100), 1000, 0.1))
+	if low < 0.2 {
+		t.Errorf("1kHz square wave power %v, want about 0.25", low)
+	}
+
+	//Above Nyquist there must be next to nothing left to alias back down.
+	for _, rate := range []int{44100, 48000} {
+		high := acPower(squareWave(NewResampler(cpuClockRate, rate), 30000, 0.1))
+		if high > 0.25e-3 {
+			t.Errorf("%dHz: 30kHz square wave power %v, want below -30dB", rate, high)
+		}
+	}
+}
+
+func TestAudioSink(t *testing.T) {
+	system := newTestSystem(t, buildTestROM(0, 2, 1, spinProgram))
+	sink := &MemorySink{Rate: 48000}
+	system.SetAudioSink(sink)
+
+	playPulse(&system.memory)
+
+	cycles := 0
+	for i := 0; i < 30; i++ {
+		cycles += system.StepFrame()
+	}
+	want := float64(cycles) * 48000 / cpuClockRate
+	if got := float64(len(sink.Samples)); math.Abs(got-want) > 1 {
+		t.Errorf("got %v samples for %d cycles, want %v", got, cycles, want)
+	}
+	if acPower(sink.Samples) == 0 {
+		t.Error("pulse channel is silent")
+	}
+
+	//The sink stays attached across a power cycle.
+	system.ResetSystem()
+	before := len(sink.Samples)
+	system.StepFrame()
+	if len(sink.Samples) == before {
+		t.Error("no samples after reset")
+	}
+}
+
+//playPulse starts pulse 1 on a 50% duty, full volume, roughly 440Hz tone.
+func playPulse(memory *Memory) {
+	memory.WriteByte(0x4015, 0x01)
+	memory.WriteByte(0x4000, 0xBF)
+	memory.WriteByte(0x4002, 0xFD)
+	memory.WriteByte(0x4003, 0x00)
+}
+
+func TestChannelSink(t *testing.T) {
+	system := newTestSystem(t, buildTestROM(0, 2, 1, spinProgram))
+	mix := &MemorySink{Rate: 44100}
+	var channels [channelCount]*MemorySink
+	system.SetAudioSink(mix)
+	for i := range channels {
+		channels[i] = &MemorySink{Rate: 44100}
+		system.SetChannelSink(i, channels[i])
+	}
+	playPulse(&system.memory)
+	for i := 0; i < 10; i++ {
+		system.StepFrame()
+	}
+
+	//With only pulse 1 playing its capture is the mix, and every other channel is silent.
+	if !equalSamples(channels[ChannelPulse1].Samples, mix.Samples) {
+		t.Error("pulse 1 capture differs from the mix")
+	}
+	for i := ChannelPulse2; i < channelCount; i++ {
+		if acPower(channels[i].Samples) != 0 {
+			t.Errorf("%s is not silent", ChannelNames[i])
+		}
+	}
+
+	system.SetChannelSink(ChannelPulse1, nil)
+	before := len(channels[ChannelPulse1].Samples)
+	system.StepFrame()
+	if len(channels[ChannelPulse1].Samples) != before {
+		t.Error("channel still captured after its sink was removed")
+	}
+}
+
+func equalSamples(a []float32, b []float32) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestWAVWriter(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "capture.wav")
+	file, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writer, err := NewWAVWriter(file, 48000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writer.WriteSamples([]float32{0, 0.5, -0.5})
+	writer.WriteSamples([]float32{1, 2, -2})
+	if err := writer.Close(); err != nil {
+		t.Fatal(err)
+	}
+	file.Close()
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(data) != wavHeaderSize+6*2 {
+		t.Fatalf("file is %d bytes, want %d", len(data), wavHeaderSize+6*2)
+	}
+	if string(data[0:4]) != "RIFF" || string(data[8:16]) != "WAVEfmt " || string(data[36:40]) != "data" {
+		t.Errorf("bad header % x", data[:wavHeaderSize])
+	}
+	if size := binary.LittleEndian.Uint32(data[4:]); size != uint32(len(data)-8) {
+		t.Errorf("RIFF size %d, want %d", size, len(data)-8)
+	}
+	if size := binary.LittleEndian.Uint32(data[40:]); size != 12 {
+		t.Errorf("data size %d, want 12", size)
+	}
+	if rate := binary.LittleEndian.Uint32(data[24:]); rate != 48000 {
+		t.Errorf("sample rate %d, want 48000", rate)
+	}
+	want := []int16{0, 16383, -16383, 32767, 32767, -32767}
+	for i, sample := range want {
+		if got := int16(binary.LittleEndian.Uint16(data[wavHeaderSize+i*2:])); got != sample {
+			t.Errorf("sample %d is %d, want %d", i, got, sample)
+		}
+	}
+}
+
+func TestRawWriterHeadless(t *testing.T) {
+	//Capturing runs at emulation speed, so a raw capture can be compared with a MemorySink exactly.
+	system := newTestSystem(t, buildTestROM(0, 2, 1, spinProgram))
+	var raw bytes.Buffer
+	writer := NewRawWriter(&raw, 44100)
+	system.SetAudioSink(writer)
+	playPulse(&system.memory)
+	for i := 0; i < 5; i++ {
+		system.StepFrame()
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	reference := newTestSystem(t, buildTestROM(0, 2, 1, spinProgram))
+	sink := &MemorySink{Rate: 44100}
+	reference.SetAudioSink(sink)
+	playPulse(&reference.memory)
+	for i := 0; i < 5; i++ {
+		reference.StepFrame()
+	}
+
+	samples := make([]float32, raw.Len()/4)
+	if err := binary.Read(&raw, binary.LittleEndian, samples); err != nil && err != io.EOF {
+		t.Fatal(err)
+	}
+	if !equalSamples(samples, sink.Samples) {
+		t.Error("raw capture differs from the reference run")
+	}
+}
+
+func TestMixer(t *testing.T) {
+	var mixer Mixer
+	mixer.Reset()
+
+	//The general path must agree with the lookup tables at full volume.
+	mixer.unity = false
+	for _, levels := range [][5]byte{{0, 0, 0, 0, 0}, {15, 15, 15, 15, 127}, {3, 9, 7, 0, 64}, {0, 1, 0, 1, 1}} {
+		want := pulseTable[levels[0]+levels[1]] + tndTable[3*levels[2]+2*levels[3]+levels[4]]
+		if got := mixer.mix(levels[0], levels[1], levels[2], levels[3], levels[4], 0); math.Abs(float64(got-want)) > 1e-6 {
+			t.Errorf("mix%v = %v, want %v", levels, got, want)
+		}
+	}
+	mixer.Reset()
+
+	mixer.SetMuted(ChannelPulse1, true)
+	if got, want := mixer.mix(10, 5, 7, 3, 20, 0), pulseTable[5]+tndTable[3*7+2*3+20]; got != want {
+		t.Errorf("pulse 1 muted: got %v, want %v", got, want)
+	}
+	mixer.SetMuted(ChannelPulse1, false)
+
+	mixer.SetSolo(ChannelTriangle, true)
+	if got, want := mixer.mix(10, 5, 7, 3, 20, 0), tndTable[3*7]; got != want {
+		t.Errorf("triangle soloed: got %v, want %v", got, want)
+	}
+	mixer.SetSolo(ChannelTriangle, false)
+
+	mixer.SetVolume(ChannelPulse1, 0.5)
+	if got, want := mixer.mix(10, 0, 0, 0, 0, 0), pulseTable[5]; got != want {
+		t.Errorf("pulse 1 at half volume: got %v, want %v", got, want)
+	}
+	mixer.SetVolume(ChannelPulse1, 1)
+	if !mixer.unity {
+		t.Error("mixer not back at unity after restoring the volume")
+	}
+}
+
+func TestMixerSurvivesReset(t *testing.T) {
+	system := newTestSystem(t, buildTestROM(0, 2, 1, spinProgram))
+	sink := &MemorySink{Rate: 44100}
+	system.SetAudioSink(sink)
+	system.Mixer().SetMuted(ChannelPulse1, true)
+	system.ResetSystem()
+	playPulse(&system.memory)
+	for i := 0; i < 5; i++ {
+		system.StepFrame()
+	}
+	if !system.Mixer().Muted(ChannelPulse1) {
+		t.Fatal("mute was lost on reset")
+	}
+	if power := acPower(sink.Samples); power != 0 {
+		t.Errorf("muted pulse 1 has power %v, want silence", power)
+	}
+}
+
+const testAudioMapperID = 4094
+
+//testSoundBoard is a 32KB PRG board with a sound chip that plays a square wave at the volume
+//written to $8000, flipping every 100 CPU cycles.
+type testSoundBoard struct {
+	testBoard
+	volume byte
+	clocks uint64
+}
+
+func (board *testSoundBoard) WriteByte(address uint16, value byte) {
+	if address >= 0x8000 {
+		board.volume = value
+		return
+	}
+	board.testBoard.WriteByte(address, value)
+}
+
+func (board *testSoundBoard) StepAudio() {
+	board.clocks++
+}
+
+func (board *testSoundBoard) AudioOutput() float32 {
+	if (board.clocks/100)%2 == 0 {
+		return 0
+	}
+	return float32(board.volume) / 15 * ExpansionPulseLevel
+}
+
+func (board *testSoundBoard) SaveAudio(encoder *gob.Encoder) error {
+	return encodeAll(encoder, board.volume, board.clocks)
+}
+
+func (board *testSoundBoard) LoadAudio(decoder *gob.Decoder) error {
+	return decodeAll(decoder, &board.volume, &board.clocks)
+}
+
+func init() {
+	RegisterMapper(testAudioMapperID, AnySubmapper, func(memory *Memory) Mapper {
+		return &testSoundBoard{testBoard: testBoard{memory: memory}}
+	})
+}
+
+func TestAudioExpansion(t *testing.T) {
+	system := newTestSystem(t, buildNES2TestROM(testAudioMapperID, 0, spinProgram))
+	board := system.memory.mapper.(*testSoundBoard)
+	if !system.HasAudioExpansion() {
+		t.Fatal("sound board not detected")
+	}
+	sink := &MemorySink{Rate: 44100}
+	expansion := &MemorySink{Rate: 44100}
+	system.SetAudioSink(sink)
+	system.SetChannelSink(ChannelExpansion, expansion)
+	system.SetFilter(FilterNone)
+	system.memory.WriteByte(0x8000, 15)
+
+	cycles := system.StepFrame()
+	if board.clocks != uint64(cycles) {
+		t.Errorf("chip clocked %d times in %d CPU cycles", board.clocks, cycles)
+	}
+	//The APU is silent, so the mix is the chip alone.
+	if !equalSamples(sink.Samples, expansion.Samples) {
+		t.Error("mix differs from the expansion channel with the APU silent")
+	}
+	//The square wave spends half its time at a full volume pulse's level.
+	if mean := meanSample(sink.Samples); math.Abs(mean-ExpansionPulseLevel/2) > 0.01 {
+		t.Errorf("mean %v, want about %v", mean, ExpansionPulseLevel/2)
+	}
+
+	system.Mixer().SetMuted(ChannelExpansion, true)
+	sink.Samples = nil
+	system.StepFrame()
+	system.StepFrame()
+	if acPower(sink.Samples) != 0 {
+		t.Error("muted expansion still audible")
+	}
+	system.Mixer().Reset()
+
+	var state bytes.Buffer
+	if err := system.SaveState(&state); err != nil {
+		t.Fatal(err)
+	}
+	clocks := board.clocks
+	system.memory.WriteByte(0x8000, 3)
+	system.StepFrame()
+	if err := system.LoadState(&state); err != nil {
+		t.Fatal(err)
+	}
+	if board.clocks != clocks || board.volume != 15 {
+		t.Errorf("chip restored to clocks %d volume %d, want %d and 15", board.clocks, board.volume, clocks)
+	}
+}
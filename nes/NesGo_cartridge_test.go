@@ -0,0 +1,193 @@
+package nes
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func TestVerification(t *testing.T) {
+
+}
+
+func TestLoadROMErrors(t *testing.T) {
+	valid := buildTestROM(0, 1, 1, storeProgram(0))
+
+	badMagic := append([]byte{}, valid...)
+	badMagic[3] = 0
+	if _, err := LoadROM(bytes.NewReader(badMagic)); !errors.Is(err, ErrBadMagic) {
+		t.Errorf("bad magic: got %v, want %v", err, ErrBadMagic)
+	}
+
+	if _, err := LoadROM(bytes.NewReader(valid[:8])); !errors.Is(err, ErrBadMagic) {
+		t.Errorf("short header: got %v, want %v", err, ErrBadMagic)
+	}
+
+	noPRG := append([]byte{}, valid...)
+	noPRG[4] = 0
+	if _, err := LoadROM(bytes.NewReader(noPRG)); !errors.Is(err, ErrBadPRGSize) {
+		t.Errorf("no PRG: got %v, want %v", err, ErrBadPRGSize)
+	}
+
+	truncated := valid[:headerSize+prgRomBankSize/2]
+	if _, err := LoadROM(bytes.NewReader(truncated)); !errors.Is(err, ErrTruncatedPRG) {
+		t.Errorf("truncated PRG: got %v, want %v", err, ErrTruncatedPRG)
+	}
+
+	truncated = valid[:len(valid)-1]
+	if _, err := LoadROM(bytes.NewReader(truncated)); !errors.Is(err, ErrTruncatedCHR) {
+		t.Errorf("truncated CHR: got %v, want %v", err, ErrTruncatedCHR)
+	}
+
+	var unsupported ErrUnsupportedMapper
+	_, err := LoadROM(bytes.NewReader(buildTestROM(5, 1, 1, nil)))
+	if !errors.As(err, &unsupported) || unsupported.ID != 5 {
+		t.Errorf("unsupported mapper: got %v, want mapper 5", err)
+	}
+
+	if _, err := LoadROM(bytes.NewReader(valid)); err != nil {
+		t.Errorf("valid rom: %v", err)
+	}
+}
+
+func TestResetWithoutCartridge(t *testing.T) {
+	if err := New().ResetSystem(); !errors.Is(err, ErrNoCartridge) {
+		t.Errorf("got %v, want %v", err, ErrNoCartridge)
+	}
+}
+
+func TestNES2Header(t *testing.T) {
+	rom := buildTestROM(4, 2, 0, storeProgram(0))
+	rom[7] |= 0x08      // NES 2.0 identifier
+	rom[8] = 0x10       // submapper 1
+	rom[10] = 0x97      // 8KB PRG-RAM, 32KB PRG-NVRAM
+	rom[11] = 0x07      // 8KB CHR-RAM
+	rom[12] = timingPAL // CPU/PPU timing
+	rom[15] = 0x08      // default expansion device
+	cartridge, err := LoadROM(bytes.NewReader(rom))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	header := cartridge.header
+	if !header.NES2 || header.MapperNumber != 4 || header.SubmapperNumber != 1 {
+		t.Errorf("mapper %d.%d (NES 2.0 %v), want 4.1", header.MapperNumber, header.SubmapperNumber, header.NES2)
+	}
+	if header.SizeRomPRG != 2*prgRomBankSize || header.SizeRomCHR != 0 {
+		t.Errorf("PRG %d CHR %d, want %d and 0", header.SizeRomPRG, header.SizeRomCHR, 2*prgRomBankSize)
+	}
+	if header.SizeRAMPRG != 8192 || header.SizeNVRAMPRG != 32768 || header.SizeRAMCHR != 8192 {
+		t.Errorf("PRG-RAM %d PRG-NVRAM %d CHR-RAM %d", header.SizeRAMPRG, header.SizeNVRAMPRG, header.SizeRAMCHR)
+	}
+	if header.TimingMode != timingPAL || header.ExpansionDevice != 0x08 || header.ConsoleType != consoleNES {
+		t.Errorf("timing %d expansion %d console %d", header.TimingMode, header.ExpansionDevice, header.ConsoleType)
+	}
+	if len(cartridge.chr) != 8192 {
+		t.Errorf("CHR-RAM allocated %d bytes, want 8192", len(cartridge.chr))
+	}
+
+	rom[8] = 0x01 // mapper 260
+	var unsupported ErrUnsupportedMapper
+	if _, err := LoadROM(bytes.NewReader(rom)); !errors.As(err, &unsupported) || unsupported.ID != 260 {
+		t.Errorf("got %v, want unsupported mapper 260", err)
+	}
+}
+
+func TestNES2RomSize(t *testing.T) {
+	tests := []struct {
+		lsb, msb byte
+		want     int
+	}{
+		{0x02, 0x0, 2 * prgRomBankSize},
+		{0x00, 0x1, 256 * prgRomBankSize},
+		{0x0E << 2, 0xF, 1 << 14},
+		{0x0E<<2 | 0x01, 0xF, 3 << 14},
+	}
+	for _, test := range tests {
+		if got := nes2RomSize(test.lsb, test.msb, prgRomBankSize); got != test.want {
+			t.Errorf("nes2RomSize(%#02x, %#x) = %d, want %d", test.lsb, test.msb, got, test.want)
+		}
+	}
+}
+
+func TestNES2SmallSizes(t *testing.T) {
+	rom := buildTestROM(0, 1, 0, storeProgram(0))
+	rom[7] |= 0x08 // NES 2.0 identifier
+	rom[9] = 0x0F
+	rom[4] = 0x0D << 2 // 8KB PRG
+	if _, err := LoadROM(bytes.NewReader(rom)); !errors.Is(err, ErrBadPRGSize) {
+		t.Errorf("8KB PRG: got %v, want %v", err, ErrBadPRGSize)
+	}
+
+	rom = buildTestROM(0, 1, 1, storeProgram(0))
+	rom[7] |= 0x08
+	rom[9] = 0xF0
+	rom[5] = 0x0C << 2 // 4KB CHR-ROM
+	if _, err := LoadROM(bytes.NewReader(rom)); !errors.Is(err, ErrBadCHRSize) {
+		t.Errorf("4KB CHR-ROM: got %v, want %v", err, ErrBadCHRSize)
+	}
+
+	for _, mapper := range []byte{0, 3} {
+		rom = buildTestROM(mapper, 1, 0, storeProgram(0))
+		rom[7] |= 0x08
+		rom[11] = 0x06 // 4KB CHR-RAM
+		system := newTestSystem(t, rom)
+		if len(system.cartridge.chr) != chrRomBankSize {
+			t.Errorf("mapper %d: CHR-RAM allocated %d bytes, want %d", mapper, len(system.cartridge.chr), chrRomBankSize)
+		}
+		// Selecting a bank and reading the top of CHR must not run off the end.
+		system.memory.WriteByte(0x8000, 0x01)
+		system.memory.mapper.ReadByte(0x1FFF)
+		system.StepFrame()
+	}
+}
+
+func TestBatteryRAM(t *testing.T) {
+	rom := buildTestROM(1, 2, 1, storeProgram(0))
+	rom[6] |= 0x02
+	system := newTestSystem(t, rom)
+	if !system.HasBattery() || !system.cartridge.header.BatteryBacked {
+		t.Fatal("battery flag not detected")
+	}
+	system.memory.WriteByte(0x6000, 0x5A)
+	system.memory.WriteByte(0x7FFF, 0xA5)
+	var save bytes.Buffer
+	if err := system.SaveBattery(&save); err != nil {
+		t.Fatal(err)
+	}
+	if save.Len() != prgRAMBankSize {
+		t.Errorf("saved %d bytes, want %d", save.Len(), prgRAMBankSize)
+	}
+
+	restored := newTestSystem(t, rom)
+	if err := restored.LoadBattery(&save); err != nil {
+		t.Fatal(err)
+	}
+	if restored.memory.ReadByte(0x6000) != 0x5A || restored.memory.ReadByte(0x7FFF) != 0xA5 {
+		t.Error("battery RAM was not restored")
+	}
+
+	plain := newTestSystem(t, buildTestROM(1, 2, 1, storeProgram(0)))
+	if err := plain.SaveBattery(&save); !errors.Is(err, ErrNoBattery) {
+		t.Errorf("no battery: got %v, want %v", err, ErrNoBattery)
+	}
+}
+
+func TestBatteryRAMAfterReset(t *testing.T) {
+	for _, mapper := range []byte{1, 4} {
+		rom := buildTestROM(mapper, 2, 1, storeProgram(0))
+		rom[6] |= 0x02
+		system := newTestSystem(t, rom)
+		system.memory.WriteByte(0x6000, 0x42)
+		if err := system.ResetSystem(); err != nil {
+			t.Fatal(err)
+		}
+		var save bytes.Buffer
+		if err := system.SaveBattery(&save); err != nil {
+			t.Fatal(err)
+		}
+		if got := save.Bytes()[0]; got != 0x42 {
+			t.Errorf("mapper %d: $6000 after reset = %#02x, want 0x42", mapper, got)
+		}
+	}
+}
@@ -0,0 +1,120 @@
+package nes
+
+import (
+	"bytes"
+	"encoding/gob"
+	"testing"
+)
+
+func testResetController(t *testing.T) {
+
+}
+
+//readA strobes the controller in port 0 and returns whether the game would see A held.
+func readA(system *System) bool {
+	system.memory.WriteByte(0x4016, 1)
+	system.memory.WriteByte(0x4016, 0)
+	return system.memory.ReadByte(0x4016)&1 != 0
+}
+
+func TestTurbo(t *testing.T) {
+	system := newTestSystem(t, buildTestROM(4, 4, 0, counterProgram))
+	if rate := system.TurboRate(0); rate != DefaultTurboRate {
+		t.Errorf("turbo rate %d before setting one, want %d", rate, DefaultTurboRate)
+	}
+	system.SetTurboRate(0, 1)
+	if rate := system.TurboRate(0); rate != MinTurboRate {
+		t.Errorf("turbo rate %d after setting 1, want %d", rate, MinTurboRate)
+	}
+
+	system.SetTurboRate(0, 4)
+	var turbo [8]bool
+	turbo[ButtonA] = true
+	system.SetTurbo(0, turbo)
+	want := []bool{true, true, false, false, true, true, false, false}
+	for frame, pressed := range want {
+		if got := readA(system); got != pressed {
+			t.Errorf("frame %d: A is %v, want %v", frame, got, pressed)
+		}
+		system.StepFrame()
+	}
+
+	// Letting go restarts the pattern so the next press lands on the next frame.
+	system.StepFrame()
+	system.SetTurbo(0, [8]bool{})
+	system.StepFrame()
+	system.SetTurbo(0, turbo)
+	if !readA(system) {
+		t.Error("A is not pressed on the first frame of autofire")
+	}
+	var buttons [8]bool
+	buttons[ButtonA] = true
+	system.SetButtons(0, buttons)
+	for frame := 0; frame < 4; frame++ {
+		if !readA(system) {
+			t.Errorf("frame %d: A held normally is released by autofire", frame)
+		}
+		system.StepFrame()
+	}
+}
+
+//testDevice drives fixed data lines and records the OUT pins, like a simple expansion port device.
+type testDevice struct {
+	data  [2]byte
+	out   byte
+	reads int
+}
+
+func (device *testDevice) Read(port int) byte {
+	device.reads++
+	return device.data[port]
+}
+
+func (device *testDevice) Write(out byte) {
+	device.out = out
+}
+
+func (device *testDevice) Save(encoder *gob.Encoder) error {
+	return encoder.Encode(device.reads)
+}
+
+func (device *testDevice) Load(decoder *gob.Decoder) error {
+	return decoder.Decode(&device.reads)
+}
+
+func TestInputDevices(t *testing.T) {
+	system := newTestSystem(t, buildTestROM(4, 4, 0, counterProgram))
+	device := &testDevice{data: [2]byte{0x02, 0xFE}}
+	system.SetInputDevice(ExpansionPort, device)
+	system.SetInputDevice(1, nil)
+	var buttons [8]bool
+	buttons[ButtonA] = true
+	system.SetButtons(0, buttons)
+
+	system.memory.WriteByte(0x4016, 0xFD)
+	if device.out != 0x05 {
+		t.Errorf("device got OUT pins %#02x, want 0x05", device.out)
+	}
+	system.memory.WriteByte(0x4016, 0)
+	if value := system.memory.ReadByte(0x4016); value != 0x43 {
+		t.Errorf("$4016 read %#02x, want the controller and expansion port combined, 0x43", value)
+	}
+	if value := system.memory.ReadByte(0x4017); value != 0x5E {
+		t.Errorf("$4017 read %#02x, want only D0-D4 of the expansion port, 0x5E", value)
+	}
+	if system.InputDevice(0) != InputDevice(&system.controller[0]) {
+		t.Error("port 0 does not hold the standard controller")
+	}
+
+	var state bytes.Buffer
+	if err := system.SaveState(&state); err != nil {
+		t.Fatal(err)
+	}
+	device.reads = 0
+	if err := system.LoadState(&state); err != nil {
+		t.Fatal(err)
+	}
+	if device.reads != 2 {
+		t.Errorf("device state loaded as %d reads, want 2", device.reads)
+	}
+}
@@ -0,0 +1,189 @@
+package nes
+
+import (
+	"bytes"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestNestest(t *testing.T) {
+	file, err := os.Open("../test-roms/nestest.nes")
+	if err != nil {
+		t.Skipf("nestest.nes not available: %v", err)
+	}
+	defer file.Close()
+
+	system := New()
+	if err := system.LoadROM(file); err != nil {
+		t.Fatal(err)
+	}
+	//Run nestest in automation mode.
+	system.cpu.pc = 0xC000
+	for i := 0; i < 60; i++ {
+		system.StepFrame()
+	}
+	if result := system.memory.RAM[0x02]; result != 0 {
+		t.Errorf("nestest reported failure code %#02x", result)
+	}
+}
+
+//runInstructions runs count instructions of program, loaded at $E000, and returns the system
+//and the cycles the last instruction took.
+func runInstructions(t testing.TB, program []byte, count int) (*System, int) {
+	system := New()
+	if err := system.LoadROM(bytes.NewReader(buildTestROM(0, 2, 1, program))); err != nil {
+		t.Fatal(err)
+	}
+	cycles := 0
+	for i := 0; i < count; i++ {
+		cycles = system.cpu.Emulate(1)
+	}
+	return system, cycles
+}
+
+func TestInstructions(t *testing.T) {
+	tests := []struct {
+		name    string
+		program []byte
+		count   int
+		a, x, y byte
+		p       byte
+		cycles  int
+	}{
+		{"ADC overflow", []byte{0xA9, 0x50, 0x69, 0x50}, 2, 0xA0, 0, 0, 0xE4, 2},
+		{"ADC carry", []byte{0x38, 0xA9, 0xFF, 0x69, 0x00}, 3, 0x00, 0, 0, 0x27, 2},
+		{"SBC borrow", []byte{0x38, 0xA9, 0x00, 0xE9, 0x01}, 3, 0xFF, 0, 0, 0xA4, 2},
+		{"CMP equal", []byte{0xA9, 0x40, 0xC9, 0x40}, 2, 0x40, 0, 0, 0x27, 2},
+		{"LDA abs,X page cross", []byte{0xA2, 0xFF, 0xBD, 0x01, 0xE0}, 2, 0x00, 0xFF, 0, 0x26, 5},
+		{"STA abs,X no penalty", []byte{0xA2, 0xFF, 0x9D, 0x01, 0x02}, 2, 0, 0xFF, 0, 0xA4, 5},
+		{"LDA (zp),Y", []byte{0xA9, 0x00, 0x85, 0x10, 0xA9, 0xE0, 0x85, 0x11, 0xA0, 0x02, 0xB1, 0x10}, 6, 0x85, 0, 0x02, 0xA4, 5},
+		{"ROR through carry", []byte{0x38, 0xA9, 0x02, 0x6A}, 3, 0x81, 0, 0, 0xA4, 2},
+		{"branch taken", []byte{0xA9, 0x01, 0xD0, 0x00}, 2, 0x01, 0, 0, 0x24, 3},
+		{"branch not taken", []byte{0xA9, 0x00, 0xD0, 0x00}, 2, 0x00, 0, 0, 0x26, 2},
+		{"JSR and RTS", []byte{0x20, 0x06, 0xE0, 0xA0, 0x07, 0x00, 0xA2, 0x05, 0x60}, 4, 0, 0x05, 0x07, 0x24, 2},
+		{"JMP indirect page wrap", []byte{0xA9, 0x34, 0x8D, 0xFF, 0x02, 0xA9, 0xE0, 0x8D, 0x00, 0x02, 0x6C, 0xFF, 0x02, 0x34: 0xA2, 0x09}, 6, 0xE0, 0x09, 0, 0x24, 2},
+		{"unofficial LAX", []byte{0xA7, 0x00}, 1, 0, 0, 0, 0x26, 3},
+		{"unofficial DCP", []byte{0xA9, 0xFF, 0xC7, 0x00}, 2, 0xFF, 0, 0, 0x27, 5},
+	}
+	for _, test := range tests {
+		system, cycles := runInstructions(t, test.program, test.count)
+		cpu := &system.cpu
+		if cpu.accumulator != test.a || cpu.x != test.x || cpu.y != test.y {
+			t.Errorf("%s: A=%02X X=%02X Y=%02X, want A=%02X X=%02X Y=%02X", test.name, cpu.accumulator, cpu.x, cpu.y, test.a, test.x, test.y)
+		}
+		if p := cpu.statusPack(false); p != test.p {
+			t.Errorf("%s: P=%02X, want %02X", test.name, p, test.p)
+		}
+		if cycles != test.cycles {
+			t.Errorf("%s: last instruction took %d cycles, want %d", test.name, cycles, test.cycles)
+		}
+	}
+}
+
+func TestOpcodeTable(t *testing.T) {
+	for code, op := range opcodes {
+		if op.mnemonic == "" || op.execute == nil || op.cycles < 2 {
+			t.Errorf("opcode %02X is missing from the table: %+v", code, op)
+		}
+		if op.pageCycles != 0 && op.mode != modeAbsoluteX && op.mode != modeAbsoluteY && op.mode != modeIndirectY {
+			t.Errorf("opcode %02X %s has a page cross penalty without indexing", code, op.mnemonic)
+		}
+	}
+}
+
+func TestDisassemble(t *testing.T) {
+	system := newTestSystem(t, buildTestROM(0, 2, 1, counterProgram))
+	want := []string{"LDA #$1E", "STA $2001", "INC $00", "BNE $E00B", "INC $01", "JMP $E005"}
+	address := uint16(0xE000)
+	for _, line := range want {
+		var text string
+		text, address = system.Disassemble(address)
+		if text != line {
+			t.Errorf("disassembled %q, want %q", text, line)
+		}
+	}
+
+	var trace bytes.Buffer
+	system.SetTrace(&trace)
+	system.cpu.Emulate(1)
+	system.cpu.Emulate(1)
+	lines := strings.Split(trace.String(), "\n")
+	if first := "E000  A9 1E     LDA #$1E                        A:00 X:00 Y:00 P:24 SP:FD CYC:0"; lines[0] != first {
+		t.Errorf("first trace line\n%q, want\n%q", lines[0], first)
+	}
+	if !strings.HasPrefix(lines[1], "E002  8D 01 20  STA $2001") || !strings.Contains(lines[1], "A:1E") {
+		t.Errorf("second trace line %q", lines[1])
+	}
+}
+
+func TestDisassembleEveryAddress(t *testing.T) {
+	for _, mapper := range []byte{0, 1, 3, 4} {
+		system := newTestSystem(t, buildTestROM(mapper, 2, 1, counterProgram))
+		system.ppu.vBlank = 1
+		for address := 0; address <= 0xFFFF; address++ {
+			system.Disassemble(uint16(address))
+		}
+		if system.ppu.vBlank != 1 {
+			t.Errorf("mapper %d: disassembling $2002 cleared the vblank flag", mapper)
+		}
+	}
+}
+
+func TestInterrupts(t *testing.T) {
+	system, _ := runInstructions(t, spinProgram, 0)
+	cpu := &system.cpu
+	if !cpu.interruptEnabled {
+		t.Error("interrupts are not masked after reset")
+	}
+
+	cpu.triggerInterruptNMI()
+	if cycles := cpu.Emulate(1); cycles != 7 || cpu.pc != 0xE000 {
+		t.Errorf("NMI took %d cycles to $%04X, want 7 to $E000", cycles, cpu.pc)
+	}
+
+	// An IRQ raised with interrupts enabled is dropped if SEI runs before it is taken.
+	cpu.interruptEnabled = false
+	cpu.triggerInterruptIRQ()
+	cpu.interruptEnabled = true
+	sp := cpu.sp
+	if cycles := cpu.Emulate(1); cycles != 2 || cpu.sp != sp {
+		t.Errorf("masked IRQ ran %d cycles and moved SP from %02X to %02X", cycles, sp, cpu.sp)
+	}
+
+	cpu.suspended = 513
+	if cycles := cpu.Emulate(1); cycles != 1 || cpu.suspended != 512 {
+		t.Errorf("DMA stall took %d cycles leaving %d, want 1 leaving 512", cycles, cpu.suspended)
+	}
+}
+
+func TestDummyRead(t *testing.T) {
+	// $3FF2,X with X=$10 reads $3F02, a mirror of PPUSTATUS, before carrying into the high byte.
+	system, _ := runInstructions(t, []byte{0xA2, 0x10, 0xBD, 0xF2, 0x3F}, 1)
+	system.ppu.vBlank = 1
+	system.cpu.Emulate(1)
+	if system.ppu.vBlank != 0 {
+		t.Error("crossing a page did not read the address before the carry")
+	}
+}
+
+//benchmarkProgram sticks to immediate, implied and absolute instructions, so it loops the
+//same way on the bit-pattern decoder the opcode table replaced.
+var benchmarkProgram = []byte{
+	0xA9, 0x01, // LDA #$01
+	0x69, 0x03, // ADC #$03
+	0xE8,             // INX
+	0x88,             // DEY
+	0xAA,             // TAX
+	0x18,             // CLC
+	0xEA,             // NOP
+	0x4C, 0x00, 0xE0, // JMP $E000
+}
+
+func BenchmarkCPU(b *testing.B) {
+	system, _ := runInstructions(b, benchmarkProgram, 0)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		system.cpu.Emulate(1)
+	}
+}
@@ -0,0 +1,105 @@
+package nes
+
+import (
+	"math"
+	"testing"
+)
+
+//gainDB measures the steady state gain of a filter for a sine wave, in decibels.
+func gainDB(filter Filter, sampleRate float64, frequency float64) float64 {
+	samples := int(sampleRate)
+	var in, out float64
+	for i := 0; i < samples; i++ {
+		x := math.Sin(2 * math.Pi * frequency * float64(i) / sampleRate)
+		y := float64(filter.Emulate(float32(x)))
+		// Skip the first half second while the filter settles.
+		if i >= samples/2 {
+			in += x * x
+			out += y * y
+		}
+	}
+	return 10 * math.Log10(out/in)
+}
+
+func TestFilterResponse(t *testing.T) {
+	const rate = 48000
+	tests := []struct {
+		name      string
+		filter    func() Filter
+		frequency float64
+		want      float64
+	}{
+		{"low-pass at cutoff", func() Filter { return LowPassFilter(rate, 14000) }, 14000, -3.01},
+		{"low-pass pass band", func() Filter { return LowPassFilter(rate, 14000) }, 1000, -0.02},
+		{"high-pass at cutoff", func() Filter { return HighPassFilter(rate, 90) }, 90, -3.01},
+		{"high-pass stop band", func() Filter { return HighPassFilter(rate, 440) }, 44, -20.1},
+		{"biquad low-pass at cutoff", func() Filter { return BiquadLowPass(rate, 5000, math.Sqrt2/2) }, 5000, -3.01},
+		{"biquad low-pass an octave up", func() Filter { return BiquadLowPass(rate, 5000, math.Sqrt2/2) }, 10000, -14.3},
+		{"biquad high-pass at cutoff", func() Filter { return BiquadHighPass(rate, 200, math.Sqrt2/2) }, 200, -3.01},
+		{"biquad high-pass an octave down", func() Filter { return BiquadHighPass(rate, 200, math.Sqrt2/2) }, 100, -12.3},
+	}
+	for _, test := range tests {
+		if got := gainDB(test.filter(), rate, test.frequency); math.Abs(got-test.want) > 0.2 {
+			t.Errorf("%s: %.2fdB at %gHz, want %.2fdB", test.name, got, test.frequency, test.want)
+		}
+	}
+}
+
+func TestFilterChains(t *testing.T) {
+	for _, rate := range []float64{44100, 48000} {
+		nes := func() Filter { return NewFilterChain(FilterNES, float32(rate)) }
+		famicom := func() Filter { return NewFilterChain(FilterFamicom, float32(rate)) }
+
+		//The NES's two high-passes take more bass out than the Famicom's one.
+		if got := gainDB(nes(), rate, 100); got > -4 {
+			t.Errorf("%g: NES chain %.2fdB at 100Hz, want the high-passes to cut it", rate, got)
+		}
+		if got := gainDB(famicom(), rate, 100); got < -1 {
+			t.Errorf("%g: Famicom chain %.2fdB at 100Hz, want it mostly passed", rate, got)
+		}
+		for _, chain := range []func() Filter{nes, famicom} {
+			if got := gainDB(chain(), rate, 14000); math.Abs(got+3) > 0.3 {
+				t.Errorf("%g: %.2fdB at 14kHz, want the low-pass's -3dB", rate, got)
+			}
+		}
+		if chain := NewFilterChain(FilterNone, float32(rate)); len(chain) != 0 {
+			t.Errorf("%g: unfiltered chain has %d filters", rate, len(chain))
+		}
+	}
+
+	//At 22050Hz the low-pass would sit above Nyquist, so it is left out.
+	if chain := NewFilterChain(FilterNES, 22050); len(chain) != 2 {
+		t.Errorf("22050Hz NES chain has %d filters, want only the 2 high-passes", len(chain))
+	}
+}
+
+func TestSetFilter(t *testing.T) {
+	system := newTestSystem(t, buildTestROM(0, 2, 1, spinProgram))
+	sink := &MemorySink{Rate: 44100}
+	system.SetAudioSink(sink)
+	system.SetFilter(FilterNone)
+	playPulse(&system.memory)
+	system.StepFrame()
+
+	//Unfiltered, the pulse wave sits above zero; the NES's high-passes center it.
+	if mean := meanSample(sink.Samples); mean < 0.05 {
+		t.Errorf("unfiltered output averages %v, want the pulse's DC offset", mean)
+	}
+	system.SetFilter(FilterNES)
+	for i := 0; i < 10; i++ {
+		system.StepFrame()
+	}
+	sink.Samples = nil
+	system.StepFrame()
+	if mean := meanSample(sink.Samples); math.Abs(mean) > 0.01 {
+		t.Errorf("NES filtered output averages %v, want about 0", mean)
+	}
+}
+
+func meanSample(samples []float32) float64 {
+	sum := 0.0
+	for _, sample := range samples {
+		sum += float64(sample)
+	}
+	return sum / float64(len(samples))
+}
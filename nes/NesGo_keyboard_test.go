@@ -0,0 +1,58 @@
+package nes
+
+import "testing"
+
+//scanKeyboard reads the whole Family BASIC keyboard the way Family BASIC does, returning the
+//$4017 value for each row and column.
+func scanKeyboard(system *System) [familyKeyboardRows][2]byte {
+	var scan [familyKeyboardRows][2]byte
+	system.memory.WriteByte(0x4016, 0x05)
+	for row := range scan {
+		system.memory.WriteByte(0x4016, 0x04)
+		scan[row][0] = system.memory.ReadByte(0x4017) & 0x1E
+		system.memory.WriteByte(0x4016, 0x06)
+		scan[row][1] = system.memory.ReadByte(0x4017) & 0x1E
+	}
+	return scan
+}
+
+func TestFamilyKeyboard(t *testing.T) {
+	system := newTestSystem(t, buildTestROM(4, 4, 0, counterProgram))
+	system.SetInputDevice(1, nil)
+	keyboard := NewFamilyKeyboard()
+	system.SetInputDevice(ExpansionPort, keyboard)
+
+	keyboard.SetKey(KeyReturn, true)
+	keyboard.SetKey(KeyKana, true)
+	keyboard.SetKey(KeyA, true)
+	keyboard.SetKey(KeyDown, true)
+	var want [familyKeyboardRows][2]byte
+	for row := range want {
+		want[row] = [2]byte{0x1E, 0x1E}
+	}
+	want[0] = [2]byte{0x1E &^ 0x08, 0x1E &^ 0x10}
+	want[6][0] = 0x1E &^ 0x02
+	want[8][1] = 0x1E &^ 0x10
+	if scan := scanKeyboard(system); scan != want {
+		t.Errorf("scanned %x, want %x", scan, want)
+	}
+
+	keyboard.SetKey(KeyA, false)
+	if scan := scanKeyboard(system); scan[6][0] != 0x1E {
+		t.Errorf("released A still reads %#02x", scan[6][0])
+	}
+	keyboard.ReleaseKeys()
+	system.memory.WriteByte(0x4016, 0x00)
+	if value := system.memory.ReadByte(0x4017) & 0x1E; value != 0 {
+		t.Errorf("disabled keyboard drove %#02x onto $4017", value)
+	}
+
+	var input [MovieInputSize]byte
+	keyboard.SetKey(KeyF1, true)
+	keyboard.MovieInput(&input)
+	replayed := NewFamilyKeyboard()
+	replayed.SetMovieInput(&input)
+	if replayed.keys != keyboard.keys {
+		t.Errorf("movie input played back keys %x, want %x", replayed.keys, keyboard.keys)
+	}
+}
@@ -0,0 +1,129 @@
+package nes
+
+import (
+	"bytes"
+	"testing"
+)
+
+const testMapperID = 4095
+
+//testBoard is a 32KB PRG board used to exercise the mapper registry from outside the built-in mappers.
+type testBoard struct {
+	memory   *Memory
+	variant  int
+	irq      bool
+	observed []uint16
+}
+
+func (board *testBoard) ReadByte(address uint16) byte {
+	cartridge := board.memory.Cartridge()
+	switch {
+	case address <= 0x1FFF:
+		return cartridge.CHR()[address]
+	case address <= 0x2FFF:
+		return board.memory.ReadNametable(address, cartridge.MirrorMode())
+	case address >= 0x8000:
+		return cartridge.PRG()[address-0x8000]
+	}
+	return 0
+}
+
+func (board *testBoard) WriteByte(address uint16, value byte) {
+	switch {
+	case address >= 0x2000 && address <= 0x2FFF:
+		board.memory.WriteNametable(address, board.memory.Cartridge().MirrorMode(), value)
+	case address >= 0x8000:
+		board.irq = value != 0
+	}
+}
+
+func (board *testBoard) Emulate() {}
+
+func (board *testBoard) IRQPending() bool {
+	return board.irq
+}
+
+func (board *testBoard) ObservePPUBus(address uint16) {
+	board.observed = append(board.observed, address)
+}
+
+func init() {
+	for _, submapper := range []int{AnySubmapper, 1} {
+		variant := submapper
+		RegisterMapper(testMapperID, submapper, func(memory *Memory) Mapper {
+			return &testBoard{memory: memory, variant: variant}
+		})
+	}
+}
+
+//buildNES2TestROM is buildTestROM with a NES 2.0 header, for mapper numbers above 255 and submappers.
+func buildNES2TestROM(mapper uint16, submapper byte, program []byte) []byte {
+	rom := buildTestROM(byte(mapper), 2, 1, program)
+	rom[7] = byte(mapper)&0xF0 | 0x08
+	rom[8] = submapper<<4 | byte(mapper>>8)&0x0F
+	return rom
+}
+
+func TestRegisterMapperSubmapper(t *testing.T) {
+	tests := []struct {
+		submapper byte
+		variant   int
+	}{
+		{1, 1},
+		{0, AnySubmapper},
+		{2, AnySubmapper},
+	}
+	for _, test := range tests {
+		system := newTestSystem(t, buildNES2TestROM(testMapperID, test.submapper, storeProgram(0x33)))
+		board, ok := system.memory.mapper.(*testBoard)
+		if !ok {
+			t.Fatalf("submapper %d: got mapper %T", test.submapper, system.memory.mapper)
+		}
+		if board.variant != test.variant {
+			t.Errorf("submapper %d: got constructor for %d, want %d", test.submapper, board.variant, test.variant)
+		}
+		system.StepFrame()
+		if got := system.memory.RAM[0]; got != 0x33 {
+			t.Errorf("submapper %d: program stored %#02x, want 0x33", test.submapper, got)
+		}
+	}
+
+	_, err := LoadFromString(buildNES2TestROM(2000, 0, nil))
+	if _, ok := err.(ErrUnsupportedMapper); !ok {
+		t.Errorf("unregistered mapper: got %v, want ErrUnsupportedMapper", err)
+	}
+}
+
+func TestMapperCapabilities(t *testing.T) {
+	//Every IRQ re-enters the program at $E000, which increments $00 and asserts the board's IRQ again.
+	program := []byte{
+		0xE6, 0x00, // INC $00
+		0xA9, 0x01, // LDA #1
+		0x8D, 0x00, 0x80, // STA $8000
+		0x58,             // CLI
+		0x4C, 0x07, 0xE0, // JMP $E007
+	}
+	system := newTestSystem(t, buildNES2TestROM(testMapperID, 0, program))
+	board := system.memory.mapper.(*testBoard)
+	system.StepFrame()
+	if got := system.memory.RAM[0]; got < 2 {
+		t.Errorf("IRQ handler ran %d times, want the board's IRQ to reach the CPU", got-1)
+	}
+
+	system.memory.WritePPU(0x2005, 0x44)
+	if got := system.memory.ReadPPU(0x2005); got != 0x44 {
+		t.Errorf("nametable read back %#02x, want 0x44", got)
+	}
+	if len(board.observed) < 2 || board.observed[len(board.observed)-1] != 0x2005 {
+		t.Errorf("observer saw %x, want the last PPU access at $2005", board.observed)
+	}
+
+	//The board keeps no state of its own, so save states simply leave it out.
+	var state bytes.Buffer
+	if err := system.SaveState(&state); err != nil {
+		t.Fatal(err)
+	}
+	if err := system.LoadState(&state); err != nil {
+		t.Fatal(err)
+	}
+}
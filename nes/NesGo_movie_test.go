@@ -0,0 +1,243 @@
+package nes
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+//inputProgram copies controller 1 into $00 once per frame, the way games poll input.
+var inputProgram = []byte{
+	0xA9, 0x80, // LDA #$80
+	0x8D, 0x00, 0x20, // STA $2000 (NMI on)
+	0x4C, 0x05, 0xE0, // JMP $E005
+}
+
+func recordTestMovie(t *testing.T, system *System, fromPowerOn bool) *Movie {
+	recorder, err := RecordMovie(system, fromPowerOn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 20; i++ {
+		system.SetButtons(0, unpackButtons(byte(i*7)))
+		system.SetButtons(1, unpackButtons(byte(i*13)))
+		if i == 10 {
+			recorder.Reset()
+		}
+		if i == 15 {
+			recorder.PowerCycle()
+		}
+		if _, err := recorder.StepFrame(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return recorder.Movie()
+}
+
+func stateOf(system *System) []byte {
+	var state bytes.Buffer
+	system.SaveState(&state)
+	return state.Bytes()
+}
+
+func TestMoviePlaybackIsDeterministic(t *testing.T) {
+	rom := buildTestROM(4, 4, 0, counterProgram)
+	for _, fromPowerOn := range []bool{true, false} {
+		system := newTestSystem(t, rom)
+		for i := 0; i < 3; i++ {
+			system.StepFrame()
+		}
+		movie := recordTestMovie(t, system, fromPowerOn)
+		want := stateOf(system)
+
+		var binaryMovie bytes.Buffer
+		if err := WriteMovie(&binaryMovie, movie); err != nil {
+			t.Fatal(err)
+		}
+		loaded, err := ReadMovie(&binaryMovie)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		replay := newTestSystem(t, rom)
+		player, err := PlayMovie(replay, loaded)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if frames, err := player.Run(0); err != nil || frames != 20 || !player.Done() {
+			t.Errorf("played %d frames (%v), want 20", frames, err)
+		}
+		if !bytes.Equal(stateOf(replay), want) {
+			t.Errorf("replay from power on %v does not match the recording", fromPowerOn)
+		}
+	}
+}
+
+func TestMovieFrameLimit(t *testing.T) {
+	system := newTestSystem(t, buildTestROM(4, 4, 0, counterProgram))
+	movie := recordTestMovie(t, system, true)
+	player, err := PlayMovie(system, movie)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if frames, err := player.Run(5); err != nil || frames != 5 || player.Done() {
+		t.Errorf("played %d frames (%v) with a limit of 5", frames, err)
+	}
+}
+
+func TestFM2RoundTrip(t *testing.T) {
+	system := newTestSystem(t, buildTestROM(4, 4, 0, counterProgram))
+	movie := recordTestMovie(t, system, true)
+
+	var fm2 bytes.Buffer
+	if err := WriteFM2(&fm2, movie); err != nil {
+		t.Fatal(err)
+	}
+	imported, err := ReadFM2(&fm2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(imported.Frames) != len(movie.Frames) {
+		t.Fatalf("imported %d frames, want %d", len(imported.Frames), len(movie.Frames))
+	}
+	for i := range movie.Frames {
+		if imported.Frames[i] != movie.Frames[i] {
+			t.Errorf("frame %d: imported %+v, want %+v", i, imported.Frames[i], movie.Frames[i])
+		}
+	}
+
+	fromState := recordTestMovie(t, system, false)
+	if err := WriteFM2(&fm2, fromState); !errors.Is(err, ErrFM2State) {
+		t.Errorf("movie from state: got %v, want %v", err, ErrFM2State)
+	}
+}
+
+func TestReadFM2(t *testing.T) {
+	fm2 := "version 3\nemuVersion 20604\nport0 1\nport1 1\nport2 0\n" +
+		"comment author somebody\n" +
+		"|0|.......A|........||\n" +
+		"|1|R..UT...|......B.||\n" +
+		"|2|........|........||\n"
+	movie, err := ReadFM2(strings.NewReader(fm2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []MovieFrame{
+		{Buttons: [4]byte{1 << ButtonA, 0}},
+		{Buttons: [4]byte{1<<ButtonRight | 1<<ButtonUp | 1<<ButtonStart, 1 << ButtonB}, Reset: true},
+		{PowerCycle: true},
+	}
+	if len(movie.Frames) != len(want) || movie.Frames[0] != want[0] || movie.Frames[1] != want[1] || movie.Frames[2] != want[2] {
+		t.Errorf("got %+v, want %+v", movie.Frames, want)
+	}
+
+	if _, err := ReadFM2(strings.NewReader("binary 1\n")); !errors.Is(err, ErrBadMovie) {
+		t.Errorf("binary FM2: got %v, want %v", err, ErrBadMovie)
+	}
+}
+
+func TestMovieIgnoresBatteryRAM(t *testing.T) {
+	rom := buildTestROM(1, 2, 1, storeProgram(0))
+	rom[6] |= 0x02
+	system := newTestSystem(t, rom)
+	system.memory.WriteByte(0x6000, 0x11)
+	movie := recordTestMovie(t, system, true)
+	want := stateOf(system)
+
+	//A different save file must not change how the movie plays.
+	replay := newTestSystem(t, rom)
+	replay.memory.WriteByte(0x6000, 0x22)
+	player, err := PlayMovie(replay, movie)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := player.Run(0); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(stateOf(replay), want) {
+		t.Error("replay with different battery RAM does not match the recording")
+	}
+}
+
+func TestReadMovieBadStateLength(t *testing.T) {
+	system := newTestSystem(t, buildTestROM(4, 4, 0, counterProgram))
+	var movie bytes.Buffer
+	if err := WriteMovie(&movie, recordTestMovie(t, system, false)); err != nil {
+		t.Fatal(err)
+	}
+	//Claim a 4GB state; the reader must notice the file is too short instead of allocating it.
+	data := movie.Bytes()
+	offset := len(movieMagic) + 2 + len(system.cartridge.hash) + 4
+	copy(data[offset:], []byte{0xFF, 0xFF, 0xFF, 0xFF})
+	if _, err := ReadMovie(bytes.NewReader(data)); !errors.Is(err, ErrBadMovie) {
+		t.Errorf("got %v, want %v", err, ErrBadMovie)
+	}
+}
+
+func TestTurboMovie(t *testing.T) {
+	rom := buildTestROM(4, 4, 0, counterProgram)
+	system := newTestSystem(t, rom)
+	recorder, err := RecordMovie(system, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var turbo [8]bool
+	turbo[ButtonA] = true
+	system.SetTurbo(0, turbo)
+	for i := 0; i < 12; i++ {
+		if i == 6 {
+			system.SetTurboRate(0, 2)
+		}
+		if _, err := recorder.StepFrame(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	movie := recorder.Movie()
+	want := stateOf(system)
+
+	var binaryMovie bytes.Buffer
+	if err := WriteMovie(&binaryMovie, movie); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := ReadMovie(&binaryMovie)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range movie.Frames {
+		if loaded.Frames[i] != movie.Frames[i] {
+			t.Errorf("frame %d: loaded %+v, want %+v", i, loaded.Frames[i], movie.Frames[i])
+		}
+	}
+
+	// Playback starts with the default rate and turbo released, as at the start of the recording.
+	replay := newTestSystem(t, rom)
+	player, err := PlayMovie(replay, loaded)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := player.Run(0); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(stateOf(replay), want) {
+		t.Error("turbo movie played back to a different state")
+	}
+
+	var fm2 bytes.Buffer
+	if err := WriteFM2(&fm2, movie); err != nil {
+		t.Fatal(err)
+	}
+	imported, err := ReadFM2(&fm2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var presses []bool
+	for _, frame := range imported.Frames {
+		presses = append(presses, frame.Buttons[0]&(1<<ButtonA) != 0)
+	}
+	wantPresses := []bool{true, true, false, false, true, true, true, false, true, false, true, false}
+	if !reflect.DeepEqual(presses, wantPresses) {
+		t.Errorf("FM2 export pressed A on %v, want %v", presses, wantPresses)
+	}
+}
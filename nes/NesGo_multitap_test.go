@@ -0,0 +1,145 @@
+package nes
+
+import (
+	"bytes"
+	"testing"
+)
+
+//readSerial strobes the controllers and reads count bits from address, taking bit as the data line.
+func readSerial(system *System, address uint16, bit uint, count int) []byte {
+	system.memory.WriteByte(0x4016, 1)
+	system.memory.WriteByte(0x4016, 0)
+	bits := make([]byte, count)
+	for i := range bits {
+		bits[i] = system.memory.ReadByte(address) >> bit & 1
+	}
+	return bits
+}
+
+//serialButtons is how a controller with buttons held shifts them out, A first.
+func serialButtons(buttons ...int) []byte {
+	bits := make([]byte, 8)
+	for _, button := range buttons {
+		bits[button] = 1
+	}
+	return bits
+}
+
+func setPlayerButtons(system *System, player int, buttons ...int) {
+	var held [8]bool
+	for _, button := range buttons {
+		held[button] = true
+	}
+	system.SetButtons(player, held)
+}
+
+func TestFourScore(t *testing.T) {
+	system := newTestSystem(t, buildTestROM(4, 4, 0, counterProgram))
+	system.SetMultitap(MultitapFourScore)
+	setPlayerButtons(system, 0, ButtonA)
+	setPlayerButtons(system, 1, ButtonB)
+	setPlayerButtons(system, 2, ButtonStart)
+	setPlayerButtons(system, 3, ButtonRight)
+
+	// Player 1 or 2, then player 3 or 4, then the signature, $10 on $4016 and $20 on $4017,
+	// then 1s once the report is done.
+	var want4016, want4017 []byte
+	want4016 = append(append(append(want4016, serialButtons(ButtonA)...), serialButtons(ButtonStart)...), 0, 0, 0, 0, 1, 0, 0, 0, 1, 1)
+	want4017 = append(append(append(want4017, serialButtons(ButtonB)...), serialButtons(ButtonRight)...), 0, 0, 0, 0, 0, 1, 0, 0, 1, 1)
+	if got := readSerial(system, 0x4016, 0, 26); !bytes.Equal(got, want4016) {
+		t.Errorf("$4016 shifted out %v, want %v", got, want4016)
+	}
+	if got := readSerial(system, 0x4017, 0, 26); !bytes.Equal(got, want4017) {
+		t.Errorf("$4017 shifted out %v, want %v", got, want4017)
+	}
+
+	system.SetMultitap(MultitapNone)
+	if got := readSerial(system, 0x4016, 0, 16); !bytes.Equal(got[8:], make([]byte, 8)) {
+		t.Errorf("player 3 still shifts out of $4016 without a multitap: %v", got)
+	}
+}
+
+func TestFamicomMultitap(t *testing.T) {
+	system := newTestSystem(t, buildTestROM(4, 4, 0, counterProgram))
+	system.SetMultitap(MultitapFamicom)
+	setPlayerButtons(system, 0, ButtonA)
+	setPlayerButtons(system, 1, ButtonB)
+	setPlayerButtons(system, 2, ButtonUp)
+	setPlayerButtons(system, 3, ButtonSelect)
+
+	tests := []struct {
+		address uint16
+		bit     uint
+		want    []byte
+	}{
+		{0x4016, 0, serialButtons(ButtonA)},
+		{0x4017, 0, serialButtons(ButtonB)},
+		{0x4016, 1, serialButtons(ButtonUp)},
+		{0x4017, 1, serialButtons(ButtonSelect)},
+	}
+	for _, test := range tests {
+		if got := readSerial(system, test.address, test.bit, 8); !bytes.Equal(got, test.want) {
+			t.Errorf("D%d of $%04X shifted out %v, want %v", test.bit, test.address, got, test.want)
+		}
+	}
+	if system.Multitap() != MultitapFamicom {
+		t.Errorf("Multitap() = %d", system.Multitap())
+	}
+}
+
+func TestMultitapMovie(t *testing.T) {
+	rom := buildTestROM(4, 4, 0, counterProgram)
+	system := newTestSystem(t, rom)
+	system.SetMultitap(MultitapFourScore)
+	recorder, err := RecordMovie(system, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 10; i++ {
+		for player := 0; player < 4; player++ {
+			system.SetButtons(player, unpackButtons(byte(i*(player+3))))
+		}
+		recorder.StepFrame()
+	}
+	movie := recorder.Movie()
+	want := stateOf(system)
+
+	var binaryMovie bytes.Buffer
+	if err := WriteMovie(&binaryMovie, movie); err != nil {
+		t.Fatal(err)
+	}
+	var fm2 bytes.Buffer
+	if err := WriteFM2(&fm2, movie); err != nil {
+		t.Fatal(err)
+	}
+	for name, read := range map[string]func() (*Movie, error){
+		"binary": func() (*Movie, error) { return ReadMovie(&binaryMovie) },
+		"FM2":    func() (*Movie, error) { return ReadFM2(&fm2) },
+	} {
+		loaded, err := read()
+		if err != nil {
+			t.Fatal(name, err)
+		}
+		if loaded.Multitap != MultitapFourScore || len(loaded.Frames) != len(movie.Frames) {
+			t.Fatalf("%s: multitap %d with %d frames", name, loaded.Multitap, len(loaded.Frames))
+		}
+		for i := range movie.Frames {
+			if loaded.Frames[i] != movie.Frames[i] {
+				t.Errorf("%s frame %d: loaded %+v, want %+v", name, i, loaded.Frames[i], movie.Frames[i])
+			}
+		}
+
+		replay := newTestSystem(t, rom)
+		player, err := PlayMovie(replay, loaded)
+		if err != nil {
+			t.Fatal(err)
+		}
+		player.Run(0)
+		if replay.Multitap() != MultitapFourScore {
+			t.Errorf("%s: playback did not connect the Four Score", name)
+		}
+		if !bytes.Equal(stateOf(replay), want) {
+			t.Errorf("%s: four player movie played back to a different state", name)
+		}
+	}
+}
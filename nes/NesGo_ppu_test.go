@@ -0,0 +1,45 @@
+package nes
+
+import "testing"
+
+func testResetPPU(t *testing.T) {
+
+}
+
+func TestHeaderMirroring(t *testing.T) {
+	tests := []struct {
+		name    string
+		flags   byte
+		mappers []byte
+		//banks lists which physical nametable each of $2000, $2400, $2800 and $2C00 should hit.
+		banks [4]int
+	}{
+		{"horizontal", 0x00, []byte{0, 3}, [4]int{0, 0, 1, 1}},
+		{"vertical", 0x01, []byte{0, 3}, [4]int{0, 1, 0, 1}},
+		//MMC3 controls its own mirroring unless the board has four-screen VRAM.
+		{"four-screen", 0x08, []byte{0, 3, 4}, [4]int{0, 1, 2, 3}},
+	}
+	for _, test := range tests {
+		for _, mapper := range test.mappers {
+			rom := buildTestROM(mapper, 2, 1, storeProgram(0))
+			rom[6] |= test.flags
+			system := newTestSystem(t, rom)
+
+			for i := 0; i < 4; i++ {
+				system.memory.WritePPU(0x2000+uint16(i)*0x400, byte(i+1))
+			}
+			for i, bank := range test.banks {
+				//The last write to a physical nametable wins.
+				want := byte(0)
+				for j := range test.banks {
+					if test.banks[j] == bank {
+						want = byte(j + 1)
+					}
+				}
+				if got := system.memory.ReadPPU(0x2000 + uint16(i)*0x400); got != want {
+					t.Errorf("%s mapper %d: nametable %d read %d, want %d", test.name, mapper, i, got, want)
+				}
+			}
+		}
+	}
+}
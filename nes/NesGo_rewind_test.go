@@ -0,0 +1,87 @@
+package nes
+
+import (
+	"bytes"
+	"math/rand"
+	"testing"
+)
+
+func TestRewindRestoresHistory(t *testing.T) {
+	rom := buildTestROM(4, 4, 0, counterProgram)
+	system := newTestSystem(t, rom)
+	rewinder := NewRewinder(system, 1, 1<<20)
+	rewinder.KeyframeEvery = 4
+	for i := 0; i < 10; i++ {
+		rewinder.StepFrame()
+	}
+	if rewinder.Len() != 10 {
+		t.Fatalf("recorded %d snapshots, want 10", rewinder.Len())
+	}
+
+	//Dropping snapshots 10, 9 and 8 loads frame 8, then one frame is emulated to redraw.
+	if !rewinder.Rewind(3) {
+		t.Fatal("rewind reported no history")
+	}
+	reference := newTestSystem(t, rom)
+	for i := 0; i < 9; i++ {
+		reference.StepFrame()
+	}
+	var got, want bytes.Buffer
+	system.SaveState(&got)
+	reference.SaveState(&want)
+	if !bytes.Equal(got.Bytes(), want.Bytes()) {
+		t.Error("rewound state differs from a run to the same frame")
+	}
+
+	for rewinder.Rewind(1) {
+	}
+	if rewinder.Len() != 0 || rewinder.Size() != 0 {
+		t.Errorf("history not empty after rewinding through it: %d snapshots, %d bytes", rewinder.Len(), rewinder.Size())
+	}
+}
+
+func TestRewindBudget(t *testing.T) {
+	system := newTestSystem(t, buildTestROM(4, 4, 0, counterProgram))
+	rewinder := NewRewinder(system, 1, 4096)
+	for _, keyframeEvery := range []int{5, 1000} {
+		rewinder.Reset()
+		rewinder.KeyframeEvery = keyframeEvery
+		for i := 0; i < 100; i++ {
+			rewinder.StepFrame()
+			if rewinder.Size() > rewinder.Budget {
+				t.Fatalf("keyframe every %d: history uses %d bytes, budget is %d", keyframeEvery, rewinder.Size(), rewinder.Budget)
+			}
+		}
+		if rewinder.Len() == 0 || rewinder.Len() == 100 {
+			t.Errorf("keyframe every %d: kept %d snapshots, want some but not all", keyframeEvery, rewinder.Len())
+		}
+	}
+}
+
+func TestRewindDeltaSize(t *testing.T) {
+	rom := buildTestROM(1, 2, 0, counterProgram)
+	rom[7] |= 0x08 // NES 2.0 identifier
+	rom[10] = 0x09 // 32KB PRG-RAM
+	rom[11] = 0x07 // 8KB CHR-RAM
+	system := newTestSystem(t, rom)
+	//Random RAM doesn't compress, so only a working delta keeps the snapshots small.
+	rand.New(rand.NewSource(1)).Read(system.cartridge.prgRAM)
+	rewinder := NewRewinder(system, 1, 1<<24)
+	for i := 0; i < 10; i++ {
+		rewinder.StepFrame()
+	}
+
+	group := rewinder.groups[0]
+	if len(group.keyframe) < 32768 {
+		t.Fatalf("keyframe is %d bytes, want the PRG-RAM in it", len(group.keyframe))
+	}
+	for i, delta := range group.deltas {
+		if len(delta)*4 > len(group.keyframe) {
+			t.Errorf("delta %d is %d bytes against a %d byte keyframe", i, len(delta), len(group.keyframe))
+		}
+	}
+
+	if !rewinder.Rewind(1) {
+		t.Fatal("rewind reported no history")
+	}
+}
@@ -0,0 +1,68 @@
+package nes
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+//counterProgram turns rendering on and counts forever in $00-$01.
+var counterProgram = []byte{
+	0xA9, 0x1E, // LDA #$1E
+	0x8D, 0x01, 0x20, // STA $2001
+	0xE6, 0x00, // INC $00
+	0xD0, 0x02, // BNE +2
+	0xE6, 0x01, // INC $01
+	0x4C, 0x05, 0xE0, // JMP $E005
+}
+
+func TestSaveStateResumesExactly(t *testing.T) {
+	system := newTestSystem(t, buildTestROM(4, 4, 0, counterProgram))
+	for i := 0; i < 5; i++ {
+		system.StepFrame()
+	}
+	var state bytes.Buffer
+	if err := system.SaveState(&state); err != nil {
+		t.Fatal(err)
+	}
+	saved := state.Bytes()
+
+	for i := 0; i < 5; i++ {
+		system.StepFrame()
+	}
+	var want bytes.Buffer
+	system.SaveState(&want)
+
+	if err := system.LoadState(bytes.NewReader(saved)); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 5; i++ {
+		system.StepFrame()
+	}
+	var got bytes.Buffer
+	system.SaveState(&got)
+
+	if !bytes.Equal(got.Bytes(), want.Bytes()) {
+		t.Error("state after resuming differs from uninterrupted run")
+	}
+}
+
+func TestLoadStateErrors(t *testing.T) {
+	system := newTestSystem(t, buildTestROM(4, 4, 0, counterProgram))
+	var state bytes.Buffer
+	system.SaveState(&state)
+
+	other := newTestSystem(t, buildTestROM(4, 4, 0, storeProgram(1)))
+	if err := other.LoadState(bytes.NewReader(state.Bytes())); !errors.Is(err, ErrStateROMMismatch) {
+		t.Errorf("other ROM: got %v, want %v", err, ErrStateROMMismatch)
+	}
+
+	if err := system.LoadState(bytes.NewReader([]byte("garbage"))); !errors.Is(err, ErrBadState) {
+		t.Errorf("garbage: got %v, want %v", err, ErrBadState)
+	}
+
+	truncated := state.Bytes()[:state.Len()/2]
+	if err := system.LoadState(bytes.NewReader(truncated)); !errors.Is(err, ErrBadState) {
+		t.Errorf("truncated: got %v, want %v", err, ErrBadState)
+	}
+}
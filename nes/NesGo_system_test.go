@@ -0,0 +1,63 @@
+package nes
+
+import (
+	"bytes"
+	"testing"
+)
+
+//buildTestROM builds an iNES image whose reset vector points at program, placed at $E000.
+func buildTestROM(mapper byte, prgBanks byte, chrBanks byte, program []byte) []byte {
+	rom := make([]byte, headerSize+int(prgBanks)*prgRomBankSize+int(chrBanks)*chrRomBankSize)
+	copy(rom, "NES\x1A")
+	rom[4] = prgBanks
+	rom[5] = chrBanks
+	rom[6] = (mapper & 0x0F) << 4
+	rom[7] = mapper & 0xF0
+
+	//The last 8KB of PRG is visible at $E000 on every supported board.
+	prg := rom[headerSize : headerSize+int(prgBanks)*prgRomBankSize]
+	lastBank := prg[len(prg)-0x2000:]
+	copy(lastBank, program)
+	for vector := 0x1FFA; vector < 0x2000; vector += 2 {
+		lastBank[vector] = 0x00
+		lastBank[vector+1] = 0xE0
+	}
+	return rom
+}
+
+//storeProgram stores value to $0000 and then spins forever.
+func storeProgram(value byte) []byte {
+	return []byte{
+		0xA9, value, // LDA #value
+		0x85, 0x00, // STA $00
+		0x4C, 0x04, 0xE0, // JMP $E004
+	}
+}
+
+func newTestSystem(t *testing.T, rom []byte) *System {
+	system := New()
+	if err := system.LoadROM(bytes.NewReader(rom)); err != nil {
+		t.Fatal(err)
+	}
+	return system
+}
+
+func TestSystemsAreIndependent(t *testing.T) {
+	mmc3 := newTestSystem(t, buildTestROM(4, 4, 2, storeProgram(0x11)))
+	cnrom := newTestSystem(t, buildTestROM(3, 2, 4, storeProgram(0x22)))
+
+	for i := 0; i < 3; i++ {
+		mmc3.StepFrame()
+		cnrom.StepFrame()
+	}
+
+	if got := mmc3.memory.RAM[0]; got != 0x11 {
+		t.Errorf("MMC3 system stored %#02x, want 0x11", got)
+	}
+	if got := cnrom.memory.RAM[0]; got != 0x22 {
+		t.Errorf("CNROM system stored %#02x, want 0x22", got)
+	}
+	if mmc3.memory.cartridge == cnrom.memory.cartridge {
+		t.Error("systems share a cartridge")
+	}
+}
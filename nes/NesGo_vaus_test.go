@@ -0,0 +1,110 @@
+package nes
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+//readKnob strobes the Vaus and shifts the knob out of address on the given data line, undoing the inversion.
+func readKnob(system *System, address uint16, bit uint) int {
+	knob := 0
+	for _, b := range readSerial(system, address, bit, 8) {
+		knob = knob<<1 | int(b^1)
+	}
+	return knob
+}
+
+func TestVaus(t *testing.T) {
+	system := newTestSystem(t, buildTestROM(4, 4, 0, counterProgram))
+	vaus := NewVaus(false)
+	system.SetInputDevice(1, vaus)
+
+	vaus.SetPosition(0xA7)
+	if knob := readKnob(system, 0x4017, 4); knob != 0xA7 {
+		t.Errorf("NES Vaus knob read %#02x, want 0xa7", knob)
+	}
+	vaus.SetPosition(0)
+	if knob := readKnob(system, 0x4017, 4); knob != VausMin {
+		t.Errorf("knob turned past the end read %#02x, want %#02x", knob, VausMin)
+	}
+	vaus.SetFire(true)
+	if value := system.memory.ReadByte(0x4017); value&0x08 == 0 {
+		t.Errorf("fire not on D3 of $4017: %#02x", value)
+	}
+
+	famicom := NewVaus(true)
+	system.SetInputDevice(1, &system.controller[1])
+	system.SetInputDevice(ExpansionPort, famicom)
+	famicom.SetPosition(0xC3)
+	famicom.SetFire(true)
+	if knob := readKnob(system, 0x4017, 1); knob != 0xC3 {
+		t.Errorf("Famicom Vaus knob read %#02x, want 0xc3", knob)
+	}
+	if value := system.memory.ReadByte(0x4016); value&0x02 == 0 {
+		t.Errorf("fire not on D1 of $4016: %#02x", value)
+	}
+}
+
+func TestDeviceMovie(t *testing.T) {
+	rom := buildTestROM(4, 4, 0, counterProgram)
+	system := newTestSystem(t, rom)
+	vaus := NewVaus(false)
+	system.SetInputDevice(1, vaus)
+	zapper := NewZapper()
+	system.SetInputDevice(ExpansionPort, zapper)
+	recorder, err := RecordMovie(system, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 10; i++ {
+		vaus.SetPosition(VausMin + i*9)
+		vaus.SetFire(i%3 == 0)
+		zapper.Aim(i*20, i*10)
+		zapper.SetTrigger(i%2 == 0)
+		recorder.StepFrame()
+	}
+	movie := recorder.Movie()
+	if movie.Devices != [ExpansionPort + 1]string{"", "vaus", "zapper"} {
+		t.Fatalf("movie records devices %q", movie.Devices)
+	}
+
+	var binaryMovie bytes.Buffer
+	if err := WriteMovie(&binaryMovie, movie); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := ReadMovie(&binaryMovie)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if loaded.Devices != movie.Devices || len(loaded.Frames) != len(movie.Frames) {
+		t.Fatalf("loaded devices %q with %d frames", loaded.Devices, len(loaded.Frames))
+	}
+	for i := range movie.Frames {
+		if loaded.Frames[i] != movie.Frames[i] {
+			t.Errorf("frame %d: loaded %+v, want %+v", i, loaded.Frames[i], movie.Frames[i])
+		}
+	}
+	if err := WriteFM2(&bytes.Buffer{}, movie); !errors.Is(err, ErrFM2Input) {
+		t.Errorf("FM2 export of a Vaus movie: got %v, want %v", err, ErrFM2Input)
+	}
+
+	replay := newTestSystem(t, rom)
+	if _, err := PlayMovie(replay, loaded); !errors.Is(err, ErrMovieDevices) {
+		t.Errorf("playing without the devices: got %v, want %v", err, ErrMovieDevices)
+	}
+	replayVaus := NewVaus(false)
+	replay.SetInputDevice(1, replayVaus)
+	replay.SetInputDevice(ExpansionPort, NewZapper())
+	player, err := PlayMovie(replay, loaded)
+	if err != nil {
+		t.Fatal(err)
+	}
+	player.Run(0)
+	if replayVaus.Position() != vaus.Position() {
+		t.Errorf("knob played back to %#02x, want %#02x", replayVaus.Position(), vaus.Position())
+	}
+	if !bytes.Equal(stateOf(replay), stateOf(system)) {
+		t.Error("device movie played back to a different state")
+	}
+}
@@ -0,0 +1,59 @@
+package nes
+
+import "testing"
+
+//runToScanline emulates until the PPU reaches scanline.
+func runToScanline(system *System, scanline int) {
+	for system.ppu.scanlineCount != scanline {
+		system.Emulate()
+	}
+}
+
+//readZapper reads the Zapper in port 1 and returns whether it sees light and whether the trigger is pulled.
+func readZapper(system *System) (light bool, trigger bool) {
+	value := system.memory.ReadByte(0x4017)
+	return value&0x08 == 0, value&0x10 != 0
+}
+
+func TestZapper(t *testing.T) {
+	system := newTestSystem(t, buildTestROM(4, 4, 0, counterProgram))
+	zapper := NewZapper()
+	system.SetInputDevice(1, zapper)
+	// counterProgram only turns rendering on, so the whole picture is the backdrop colour.
+	system.ppu.palette[0] = 0x30
+	system.StepFrame()
+
+	tests := []struct {
+		name     string
+		x, y     int
+		scanline int
+		backdrop byte
+		light    bool
+	}{
+		{"white just drawn", 128, 100, 105, 0x30, true},
+		{"before the beam gets there", 128, 100, 90, 0x30, false},
+		{"long after the beam passed", 128, 100, 200, 0x30, false},
+		{"black", 128, 100, 105, 0x0F, false},
+		{"off screen", -1, -1, 105, 0x30, false},
+	}
+	for _, test := range tests {
+		system.ppu.palette[0] = test.backdrop
+		zapper.Aim(test.x, test.y)
+		system.StepFrame()
+		runToScanline(system, test.scanline)
+		if light, _ := readZapper(system); light != test.light {
+			t.Errorf("%s: sensor sees light %v, want %v", test.name, light, test.light)
+		}
+	}
+
+	if _, trigger := readZapper(system); trigger {
+		t.Error("trigger pulled before SetTrigger")
+	}
+	zapper.SetTrigger(true)
+	if _, trigger := readZapper(system); !trigger {
+		t.Error("trigger not pulled after SetTrigger")
+	}
+	if value := system.memory.ReadByte(0x4016); value&0x18 != 0 {
+		t.Errorf("the Zapper in port 1 shows up on $4016: %#02x", value)
+	}
+}
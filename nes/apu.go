@@ -1,9 +1,25 @@
-package main
+package nes
 
 //TODO: Implement own.
 import "encoding/gob"
 
-const frameCounterRate = 1789773 / 240.0
+//cpuClockRate is the NTSC CPU clock, which the APU runs from, in cycles per second.
+const cpuClockRate = 1789773
+
+//CPU cycles at which the frame counter clocks the envelopes and length counters,
+//counted from the start of the sequence. See https://wiki.nesdev.com/w/index.php/APU_Frame_Counter
+const (
+	frameStep1     = 7457
+	frameStep2     = 14913
+	frameStep3     = 22371
+	frameStep4     = 29829
+	frameStep5     = 37281
+	frameLength4   = 29830
+	frameLength5   = 37282
+	frameIRQStart  = frameStep4 - 1
+	frameResetSlow = 4
+	frameResetFast = 3
+)
 
 var lengthTable = []byte{
 	10, 254, 20, 2, 40, 4, 80, 6, 160, 8, 60, 10, 14, 12, 26, 14,
@@ -45,79 +61,146 @@ func init() {
 // APU
 
 type APU struct {
-	system      *System
-	channel     chan float32
-	sampleRate  float64
-	pulse1      Pulse
-	pulse2      Pulse
-	triangle    Triangle
-	noise       Noise
-	dmc         DMC
-	cycle       uint64
-	framePeriod byte
-	frameValue  byte
-	frameIRQ    bool
-	filterChain FilterChain
-}
-
-func (system *System) resetAPU() *APU {
-	apu := APU{}
+	system    *System
+	mixer     *Mixer
+	filter    FilterType
+	expansion AudioExpansion
+	mixOutput audioOutput
+	//channelOutputs capture each channel on its own, used only when a sink is set.
+	channelOutputs [channelCount]audioOutput
+	channelSinks   int
+	pulse1         Pulse
+	pulse2         Pulse
+	triangle       Triangle
+	noise          Noise
+	dmc            DMC
+	cycle          uint64
+	framePeriod    byte
+	//frameCounter counts CPU cycles since the start of the frame sequence.
+	frameCounter int
+	//frameReset counts down the CPU cycles until a $4017 write restarts the sequence.
+	frameReset int
+	//frameIRQ is false while $4017 inhibits the frame interrupt.
+	frameIRQ bool
+	//frameIRQFlag is the frame interrupt flag read back through $4015.
+	frameIRQFlag bool
+}
+
+func (system *System) resetAPU() {
+	filter := system.apu.filter
+	mixSink := system.apu.mixOutput.sink
+	var channelSinks [channelCount]AudioSink
+	for i := range channelSinks {
+		channelSinks[i] = system.apu.channelOutputs[i].sink
+	}
+	system.apu = APU{}
+	apu := &system.apu
 	apu.system = system
+	apu.mixer = &system.mixer
+	apu.filter = filter
+	apu.setSink(mixSink)
+	for i, sink := range channelSinks {
+		apu.setChannelSink(i, sink)
+	}
+	// Power on behaves as if $4017 had been written with $00.
+	apu.framePeriod = 4
+	apu.frameIRQ = true
 	apu.noise.shiftRegister = 1
 	apu.pulse1.channel = 1
 	apu.pulse2.channel = 2
+	// The registers power on as zero, which leaves the length counters running.
+	apu.pulse1.lengthEnabled = true
+	apu.pulse2.lengthEnabled = true
+	apu.triangle.lengthEnabled = true
+	apu.noise.lengthEnabled = true
 	apu.dmc.cpu = &system.cpu
-	return &apu
 }
 
 func (apu *APU) Save(encoder *gob.Encoder) error {
-	encoder.Encode(apu.cycle)
-	encoder.Encode(apu.framePeriod)
-	encoder.Encode(apu.frameValue)
-	encoder.Encode(apu.frameIRQ)
-	apu.pulse1.Save(encoder)
-	apu.pulse2.Save(encoder)
-	apu.triangle.Save(encoder)
-	apu.noise.Save(encoder)
-	apu.dmc.Save(encoder)
-	return nil
+	if err := encodeAll(encoder,
+		apu.cycle,
+		apu.framePeriod,
+		apu.frameCounter,
+		apu.frameReset,
+		apu.frameIRQ,
+		apu.frameIRQFlag,
+	); err != nil {
+		return err
+	}
+	return saveAll(encoder, &apu.pulse1, &apu.pulse2, &apu.triangle, &apu.noise, &apu.dmc)
 }
 
 func (apu *APU) Load(decoder *gob.Decoder) error {
-	decoder.Decode(&apu.cycle)
-	decoder.Decode(&apu.framePeriod)
-	decoder.Decode(&apu.frameValue)
-	decoder.Decode(&apu.frameIRQ)
-	apu.pulse1.Load(decoder)
-	apu.pulse2.Load(decoder)
-	apu.triangle.Load(decoder)
-	apu.noise.Load(decoder)
-	apu.dmc.Load(decoder)
-	return nil
+	if err := decodeAll(decoder,
+		&apu.cycle,
+		&apu.framePeriod,
+		&apu.frameCounter,
+		&apu.frameReset,
+		&apu.frameIRQ,
+		&apu.frameIRQFlag,
+	); err != nil {
+		return err
+	}
+	return loadAll(decoder, &apu.pulse1, &apu.pulse2, &apu.triangle, &apu.noise, &apu.dmc)
 }
 
 func (apu *APU) Step() {
-	cycle1 := apu.cycle
 	apu.cycle++
-	cycle2 := apu.cycle
 	apu.stepTimer()
-	f1 := int(float64(cycle1) / frameCounterRate)
-	f2 := int(float64(cycle2) / frameCounterRate)
-	if f1 != f2 {
-		apu.stepFrameCounter()
+	apu.stepFrameCounter()
+	if apu.expansion != nil {
+		apu.expansion.StepAudio()
+	}
+	if apu.mixOutput.sink != nil {
+		apu.mixOutput.clock(apu.output())
 	}
-	s1 := int(float64(cycle1) / apu.sampleRate)
-	s2 := int(float64(cycle2) / apu.sampleRate)
-	if s1 != s2 {
-		apu.sendSample()
+	if apu.channelSinks > 0 {
+		apu.clockChannels()
 	}
 }
 
-func (apu *APU) sendSample() {
-	output := apu.filterChain.Emulate(apu.output())
-	select {
-	case apu.channel <- output:
-	default:
+//setSink sends the mixed output to sink. A nil sink turns sampling off.
+func (apu *APU) setSink(sink AudioSink) {
+	apu.mixOutput.setSink(sink, apu.filter)
+}
+
+//setFilter rebuilds the output filters of every sink for a different output stage.
+func (apu *APU) setFilter(filter FilterType) {
+	apu.filter = filter
+	apu.mixOutput.setFilter(filter)
+	for i := range apu.channelOutputs {
+		apu.channelOutputs[i].setFilter(filter)
+	}
+}
+
+//setChannelSink sends one channel's output, as if every other channel were silent, to sink.
+func (apu *APU) setChannelSink(channel int, sink AudioSink) {
+	output := &apu.channelOutputs[channel]
+	if output.sink != nil {
+		apu.channelSinks--
+	}
+	output.setSink(sink, apu.filter)
+	if sink != nil {
+		apu.channelSinks++
+	}
+}
+
+//clockChannels feeds each captured channel through the mixer on its own,
+//so the levels match what the channel contributes to the mix.
+func (apu *APU) clockChannels() {
+	apu.channelOutputs[ChannelPulse1].clock(pulseTable[apu.pulse1.output()])
+	apu.channelOutputs[ChannelPulse2].clock(pulseTable[apu.pulse2.output()])
+	apu.channelOutputs[ChannelTriangle].clock(tndTable[3*apu.triangle.output()])
+	apu.channelOutputs[ChannelNoise].clock(tndTable[2*apu.noise.output()])
+	apu.channelOutputs[ChannelDMC].clock(tndTable[apu.dmc.output()])
+	apu.channelOutputs[ChannelExpansion].clock(apu.expansionOutput())
+}
+
+//flushSamples hands everything resampled so far to the sinks.
+func (apu *APU) flushSamples() {
+	apu.mixOutput.flush()
+	for i := range apu.channelOutputs {
+		apu.channelOutputs[i].flush()
 	}
 }
 
@@ -127,9 +210,14 @@ func (apu *APU) output() float32 {
 	t := apu.triangle.output()
 	n := apu.noise.output()
 	d := apu.dmc.output()
-	pulseOut := pulseTable[p1+p2]
-	tndOut := tndTable[3*t+2*n+d]
-	return pulseOut + tndOut
+	return apu.mixer.mix(p1, p2, t, n, d, apu.expansionOutput())
+}
+
+func (apu *APU) expansionOutput() float32 {
+	if apu.expansion == nil {
+		return 0
+	}
+	return apu.expansion.AudioOutput()
 }
 
 // mode 0:    mode 1:       function
@@ -138,31 +226,49 @@ func (apu *APU) output() float32 {
 //  - l - l    l - l - -    Length counter and sweep
 //  e e e e    e e e e -    Envelope and linear counter
 func (apu *APU) stepFrameCounter() {
+	if apu.frameReset > 0 {
+		apu.frameReset--
+		if apu.frameReset == 0 {
+			apu.frameCounter = 0
+			if apu.framePeriod == 5 {
+				apu.stepEnvelope()
+				apu.stepSweep()
+				apu.stepLength()
+			}
+		}
+	}
+
+	apu.frameCounter++
 	switch apu.framePeriod {
 	case 4:
-		apu.frameValue = (apu.frameValue + 1) % 4
-		switch apu.frameValue {
-		case 0, 2:
+		switch apu.frameCounter {
+		case frameStep1, frameStep3:
 			apu.stepEnvelope()
-		case 1:
+		case frameStep2:
 			apu.stepEnvelope()
 			apu.stepSweep()
 			apu.stepLength()
-		case 3:
+		case frameIRQStart:
+			apu.fireIRQ()
+		case frameStep4:
 			apu.stepEnvelope()
 			apu.stepSweep()
 			apu.stepLength()
 			apu.fireIRQ()
+		case frameLength4:
+			apu.fireIRQ()
+			apu.frameCounter = 0
 		}
 	case 5:
-		apu.frameValue = (apu.frameValue + 1) % 5
-		switch apu.frameValue {
-		case 1, 3:
+		switch apu.frameCounter {
+		case frameStep1, frameStep3:
 			apu.stepEnvelope()
-		case 0, 2:
+		case frameStep2, frameStep5:
 			apu.stepEnvelope()
 			apu.stepSweep()
 			apu.stepLength()
+		case frameLength5:
+			apu.frameCounter = 0
 		}
 	}
 }
@@ -198,10 +304,15 @@ func (apu *APU) stepLength() {
 
 func (apu *APU) fireIRQ() {
 	if apu.frameIRQ {
-		apu.system.cpu.triggerInterruptIRQ()
+		apu.frameIRQFlag = true
 	}
 }
 
+//irqPending reports whether the frame counter or the DMC is asserting the IRQ line.
+func (apu *APU) irqPending() bool {
+	return apu.frameIRQFlag || apu.dmc.irqFlag
+}
+
 func (apu *APU) readRegister(address uint16) byte {
 	if address == 0x4015 {
 		return apu.readStatus()
@@ -273,6 +384,14 @@ func (apu *APU) readStatus() byte {
 	if apu.dmc.currentLength > 0 {
 		result |= 16
 	}
+	if apu.frameIRQFlag {
+		result |= 64
+	}
+	if apu.dmc.irqFlag {
+		result |= 128
+	}
+	// Reading the status acknowledges the frame interrupt, but not the DMC one.
+	apu.frameIRQFlag = false
 	return result
 }
 
@@ -282,6 +401,7 @@ func (apu *APU) writeControl(value byte) {
 	apu.triangle.enabled = value&4 == 4
 	apu.noise.enabled = value&8 == 8
 	apu.dmc.enabled = value&16 == 16
+	apu.dmc.irqFlag = false
 	if !apu.pulse1.enabled {
 		apu.pulse1.lengthValue = 0
 	}
@@ -306,11 +426,14 @@ func (apu *APU) writeControl(value byte) {
 func (apu *APU) writeFrameCounter(value byte) {
 	apu.framePeriod = 4 + (value>>7)&1
 	apu.frameIRQ = (value>>6)&1 == 0
-	// apu.frameValue = 0
-	if apu.framePeriod == 5 {
-		apu.stepEnvelope()
-		apu.stepSweep()
-		apu.stepLength()
+	if !apu.frameIRQ {
+		apu.frameIRQFlag = false
+	}
+	// The sequence restarts 3 or 4 CPU cycles later, depending on where in the APU cycle the write lands.
+	if apu.cycle%2 == 0 {
+		apu.frameReset = frameResetFast
+	} else {
+		apu.frameReset = frameResetSlow
 	}
 }
 
@@ -340,53 +463,55 @@ type Pulse struct {
 }
 
 func (p *Pulse) Save(encoder *gob.Encoder) error {
-	encoder.Encode(p.enabled)
-	encoder.Encode(p.channel)
-	encoder.Encode(p.lengthEnabled)
-	encoder.Encode(p.lengthValue)
-	encoder.Encode(p.timerPeriod)
-	encoder.Encode(p.timerValue)
-	encoder.Encode(p.dutyMode)
-	encoder.Encode(p.dutyValue)
-	encoder.Encode(p.sweepReload)
-	encoder.Encode(p.sweepEnabled)
-	encoder.Encode(p.sweepNegate)
-	encoder.Encode(p.sweepShift)
-	encoder.Encode(p.sweepPeriod)
-	encoder.Encode(p.sweepValue)
-	encoder.Encode(p.envelopeEnabled)
-	encoder.Encode(p.envelopeLoop)
-	encoder.Encode(p.envelopeStart)
-	encoder.Encode(p.envelopePeriod)
-	encoder.Encode(p.envelopeValue)
-	encoder.Encode(p.envelopeVolume)
-	encoder.Encode(p.constantVolume)
-	return nil
+	return encodeAll(encoder,
+		p.enabled,
+		p.channel,
+		p.lengthEnabled,
+		p.lengthValue,
+		p.timerPeriod,
+		p.timerValue,
+		p.dutyMode,
+		p.dutyValue,
+		p.sweepReload,
+		p.sweepEnabled,
+		p.sweepNegate,
+		p.sweepShift,
+		p.sweepPeriod,
+		p.sweepValue,
+		p.envelopeEnabled,
+		p.envelopeLoop,
+		p.envelopeStart,
+		p.envelopePeriod,
+		p.envelopeValue,
+		p.envelopeVolume,
+		p.constantVolume,
+	)
 }
 
 func (p *Pulse) Load(decoder *gob.Decoder) error {
-	decoder.Decode(&p.enabled)
-	decoder.Decode(&p.channel)
-	decoder.Decode(&p.lengthEnabled)
-	decoder.Decode(&p.lengthValue)
-	decoder.Decode(&p.timerPeriod)
-	decoder.Decode(&p.timerValue)
-	decoder.Decode(&p.dutyMode)
-	decoder.Decode(&p.dutyValue)
-	decoder.Decode(&p.sweepReload)
-	decoder.Decode(&p.sweepEnabled)
-	decoder.Decode(&p.sweepNegate)
-	decoder.Decode(&p.sweepShift)
-	decoder.Decode(&p.sweepPeriod)
-	decoder.Decode(&p.sweepValue)
-	decoder.Decode(&p.envelopeEnabled)
-	decoder.Decode(&p.envelopeLoop)
-	decoder.Decode(&p.envelopeStart)
-	decoder.Decode(&p.envelopePeriod)
-	decoder.Decode(&p.envelopeValue)
-	decoder.Decode(&p.envelopeVolume)
-	decoder.Decode(&p.constantVolume)
-	return nil
+	return decodeAll(decoder,
+		&p.enabled,
+		&p.channel,
+		&p.lengthEnabled,
+		&p.lengthValue,
+		&p.timerPeriod,
+		&p.timerValue,
+		&p.dutyMode,
+		&p.dutyValue,
+		&p.sweepReload,
+		&p.sweepEnabled,
+		&p.sweepNegate,
+		&p.sweepShift,
+		&p.sweepPeriod,
+		&p.sweepValue,
+		&p.envelopeEnabled,
+		&p.envelopeLoop,
+		&p.envelopeStart,
+		&p.envelopePeriod,
+		&p.envelopeValue,
+		&p.envelopeVolume,
+		&p.constantVolume,
+	)
 }
 
 func (p *Pulse) writeControl(value byte) {
@@ -412,7 +537,9 @@ func (p *Pulse) writeTimerLow(value byte) {
 }
 
 func (p *Pulse) writeTimerHigh(value byte) {
-	p.lengthValue = lengthTable[value>>3]
+	if p.enabled {
+		p.lengthValue = lengthTable[value>>3]
+	}
 	p.timerPeriod = (p.timerPeriod & 0x00FF) | (uint16(value&7) << 8)
 	p.envelopeStart = true
 	p.dutyValue = 0
@@ -517,29 +644,31 @@ type Triangle struct {
 }
 
 func (t *Triangle) Save(encoder *gob.Encoder) error {
-	encoder.Encode(t.enabled)
-	encoder.Encode(t.lengthEnabled)
-	encoder.Encode(t.lengthValue)
-	encoder.Encode(t.timerPeriod)
-	encoder.Encode(t.timerValue)
-	encoder.Encode(t.dutyValue)
-	encoder.Encode(t.counterPeriod)
-	encoder.Encode(t.counterValue)
-	encoder.Encode(t.counterReload)
-	return nil
+	return encodeAll(encoder,
+		t.enabled,
+		t.lengthEnabled,
+		t.lengthValue,
+		t.timerPeriod,
+		t.timerValue,
+		t.dutyValue,
+		t.counterPeriod,
+		t.counterValue,
+		t.counterReload,
+	)
 }
 
 func (t *Triangle) Load(decoder *gob.Decoder) error {
-	decoder.Decode(&t.enabled)
-	decoder.Decode(&t.lengthEnabled)
-	decoder.Decode(&t.lengthValue)
-	decoder.Decode(&t.timerPeriod)
-	decoder.Decode(&t.timerValue)
-	decoder.Decode(&t.dutyValue)
-	decoder.Decode(&t.counterPeriod)
-	decoder.Decode(&t.counterValue)
-	decoder.Decode(&t.counterReload)
-	return nil
+	return decodeAll(decoder,
+		&t.enabled,
+		&t.lengthEnabled,
+		&t.lengthValue,
+		&t.timerPeriod,
+		&t.timerValue,
+		&t.dutyValue,
+		&t.counterPeriod,
+		&t.counterValue,
+		&t.counterReload,
+	)
 }
 
 func (t *Triangle) writeControl(value byte) {
@@ -552,7 +681,9 @@ func (t *Triangle) writeTimerLow(value byte) {
 }
 
 func (t *Triangle) writeTimerHigh(value byte) {
-	t.lengthValue = lengthTable[value>>3]
+	if t.enabled {
+		t.lengthValue = lengthTable[value>>3]
+	}
 	t.timerPeriod = (t.timerPeriod & 0x00FF) | (uint16(value&7) << 8)
 	t.timerValue = t.timerPeriod
 	t.counterReload = true
@@ -619,39 +750,41 @@ type Noise struct {
 }
 
 func (n *Noise) Save(encoder *gob.Encoder) error {
-	encoder.Encode(n.enabled)
-	encoder.Encode(n.mode)
-	encoder.Encode(n.shiftRegister)
-	encoder.Encode(n.lengthEnabled)
-	encoder.Encode(n.lengthValue)
-	encoder.Encode(n.timerPeriod)
-	encoder.Encode(n.timerValue)
-	encoder.Encode(n.envelopeEnabled)
-	encoder.Encode(n.envelopeLoop)
-	encoder.Encode(n.envelopeStart)
-	encoder.Encode(n.envelopePeriod)
-	encoder.Encode(n.envelopeValue)
-	encoder.Encode(n.envelopeVolume)
-	encoder.Encode(n.constantVolume)
-	return nil
+	return encodeAll(encoder,
+		n.enabled,
+		n.mode,
+		n.shiftRegister,
+		n.lengthEnabled,
+		n.lengthValue,
+		n.timerPeriod,
+		n.timerValue,
+		n.envelopeEnabled,
+		n.envelopeLoop,
+		n.envelopeStart,
+		n.envelopePeriod,
+		n.envelopeValue,
+		n.envelopeVolume,
+		n.constantVolume,
+	)
 }
 
 func (n *Noise) Load(decoder *gob.Decoder) error {
-	decoder.Decode(&n.enabled)
-	decoder.Decode(&n.mode)
-	decoder.Decode(&n.shiftRegister)
-	decoder.Decode(&n.lengthEnabled)
-	decoder.Decode(&n.lengthValue)
-	decoder.Decode(&n.timerPeriod)
-	decoder.Decode(&n.timerValue)
-	decoder.Decode(&n.envelopeEnabled)
-	decoder.Decode(&n.envelopeLoop)
-	decoder.Decode(&n.envelopeStart)
-	decoder.Decode(&n.envelopePeriod)
-	decoder.Decode(&n.envelopeValue)
-	decoder.Decode(&n.envelopeVolume)
-	decoder.Decode(&n.constantVolume)
-	return nil
+	return decodeAll(decoder,
+		&n.enabled,
+		&n.mode,
+		&n.shiftRegister,
+		&n.lengthEnabled,
+		&n.lengthValue,
+		&n.timerPeriod,
+		&n.timerValue,
+		&n.envelopeEnabled,
+		&n.envelopeLoop,
+		&n.envelopeStart,
+		&n.envelopePeriod,
+		&n.envelopeValue,
+		&n.envelopeVolume,
+		&n.constantVolume,
+	)
 }
 
 func (n *Noise) writeControl(value byte) {
@@ -669,7 +802,9 @@ func (n *Noise) writePeriod(value byte) {
 }
 
 func (n *Noise) writeLength(value byte) {
-	n.lengthValue = lengthTable[value>>3]
+	if n.enabled {
+		n.lengthValue = lengthTable[value>>3]
+	}
 	n.envelopeStart = true
 }
 
@@ -747,42 +882,50 @@ type DMC struct {
 	tickValue      byte
 	loop           bool
 	irq            bool
+	irqFlag        bool
 }
 
 func (d *DMC) Save(encoder *gob.Encoder) error {
-	encoder.Encode(d.enabled)
-	encoder.Encode(d.value)
-	encoder.Encode(d.sampleAddress)
-	encoder.Encode(d.sampleLength)
-	encoder.Encode(d.currentAddress)
-	encoder.Encode(d.currentLength)
-	encoder.Encode(d.shiftRegister)
-	encoder.Encode(d.bitCount)
-	encoder.Encode(d.tickPeriod)
-	encoder.Encode(d.tickValue)
-	encoder.Encode(d.loop)
-	encoder.Encode(d.irq)
-	return nil
+	return encodeAll(encoder,
+		d.enabled,
+		d.value,
+		d.sampleAddress,
+		d.sampleLength,
+		d.currentAddress,
+		d.currentLength,
+		d.shiftRegister,
+		d.bitCount,
+		d.tickPeriod,
+		d.tickValue,
+		d.loop,
+		d.irq,
+		d.irqFlag,
+	)
 }
 
 func (d *DMC) Load(decoder *gob.Decoder) error {
-	decoder.Decode(&d.enabled)
-	decoder.Decode(&d.value)
-	decoder.Decode(&d.sampleAddress)
-	decoder.Decode(&d.sampleLength)
-	decoder.Decode(&d.currentAddress)
-	decoder.Decode(&d.currentLength)
-	decoder.Decode(&d.shiftRegister)
-	decoder.Decode(&d.bitCount)
-	decoder.Decode(&d.tickPeriod)
-	decoder.Decode(&d.tickValue)
-	decoder.Decode(&d.loop)
-	decoder.Decode(&d.irq)
-	return nil
+	return decodeAll(decoder,
+		&d.enabled,
+		&d.value,
+		&d.sampleAddress,
+		&d.sampleLength,
+		&d.currentAddress,
+		&d.currentLength,
+		&d.shiftRegister,
+		&d.bitCount,
+		&d.tickPeriod,
+		&d.tickValue,
+		&d.loop,
+		&d.irq,
+		&d.irqFlag,
+	)
 }
 
 func (d *DMC) writeControl(value byte) {
 	d.irq = value&0x80 == 0x80
+	if !d.irq {
+		d.irqFlag = false
+	}
 	d.loop = value&0x40 == 0x40
 	d.tickPeriod = dmcTable[value&0x0F]
 }
@@ -830,8 +973,12 @@ func (d *DMC) stepReader() {
 			d.currentAddress = 0x8000
 		}
 		d.currentLength--
-		if d.currentLength == 0 && d.loop {
-			d.restart()
+		if d.currentLength == 0 {
+			if d.loop {
+				d.restart()
+			} else if d.irq {
+				d.irqFlag = true
+			}
 		}
 	}
 }
@@ -0,0 +1,125 @@
+package nes
+
+//AudioSink receives the APU's output as mono samples in the range 0 to 1 at the sink's own rate.
+//WriteSamples is called from the emulation goroutine once per frame; the slice is reused afterwards,
+//so a sink that hands the samples to another goroutine has to copy them.
+type AudioSink interface {
+	SampleRate() int
+	WriteSamples(samples []float32)
+}
+
+//The APU's channels, for capturing them separately.
+const (
+	ChannelPulse1 = iota
+	ChannelPulse2
+	ChannelTriangle
+	ChannelNoise
+	ChannelDMC
+	//ChannelExpansion is the cartridge's sound chip, if it has one.
+	ChannelExpansion
+	channelCount
+)
+
+//ChannelNames are short names for the channels, indexed by the Channel constants.
+var ChannelNames = [channelCount]string{"pulse1", "pulse2", "triangle", "noise", "dmc", "expansion"}
+
+//ExpansionPulseLevel is how loud an APU pulse channel at full volume is in the mix,
+//for scaling AudioExpansion output against.
+const ExpansionPulseLevel = 95.52 / (8128.0/15 + 100)
+
+//HasAudioExpansion reports whether the inserted cartridge has its own sound chip.
+func (system *System) HasAudioExpansion() bool {
+	return system.apu.expansion != nil
+}
+
+//SetAudioSink sends the APU's output to sink from now on, or stops producing samples if sink is nil.
+func (system *System) SetAudioSink(sink AudioSink) {
+	system.apu.setSink(sink)
+}
+
+//SetChannelSink sends a single channel to sink, or stops capturing it if sink is nil.
+//Each channel is resampled and filtered separately from the mix.
+func (system *System) SetChannelSink(channel int, sink AudioSink) {
+	system.apu.setChannelSink(channel, sink)
+}
+
+//SetFilter picks the analog output stage to emulate. The default is FilterNES.
+func (system *System) SetFilter(filter FilterType) {
+	system.apu.setFilter(filter)
+}
+
+//audioOutput resamples and filters a signal from the APU for one sink.
+type audioOutput struct {
+	sink      AudioSink
+	resampler *Resampler
+	filters   FilterChain
+	samples   []float32
+}
+
+func (output *audioOutput) setSink(sink AudioSink, filter FilterType) {
+	output.sink = sink
+	output.resampler = nil
+	if sink != nil {
+		output.resampler = NewResampler(cpuClockRate, sink.SampleRate())
+	}
+	output.setFilter(filter)
+}
+
+func (output *audioOutput) setFilter(filter FilterType) {
+	output.filters = nil
+	if output.sink != nil {
+		output.filters = NewFilterChain(filter, float32(output.sink.SampleRate()))
+	}
+}
+
+func (output *audioOutput) clock(level float32) {
+	if output.resampler != nil {
+		output.resampler.Clock(level)
+	}
+}
+
+//flush filters the samples resampled so far and hands them to the sink.
+func (output *audioOutput) flush() {
+	if output.resampler == nil {
+		return
+	}
+	count := output.resampler.Available()
+	if cap(output.samples) < count {
+		output.samples = make([]float32, count)
+	}
+	samples := output.samples[:output.resampler.Read(output.samples[:count])]
+	for i, sample := range samples {
+		samples[i] = output.filters.Emulate(sample)
+	}
+	output.sink.WriteSamples(samples)
+}
+
+//NullSink throws every sample away. It keeps the resampler running, for timing
+//comparisons with a real device, without playing anything.
+type NullSink struct {
+	Rate int
+}
+
+//SampleRate returns the rate the sink was created with.
+func (sink NullSink) SampleRate() int {
+	return sink.Rate
+}
+
+//WriteSamples discards samples.
+func (sink NullSink) WriteSamples(samples []float32) {}
+
+//MemorySink collects every sample in memory, for tests and offline processing.
+type MemorySink struct {
+	Rate    int
+	Samples []float32
+}
+
+//SampleRate returns the rate the sink was created with.
+func (sink *MemorySink) SampleRate() int {
+	return sink.Rate
+}
+
+//WriteSamples appends samples to Samples.
+func (sink *MemorySink) WriteSamples(samples []float32) {
+	sink.Samples = append(sink.Samples, samples...)
+}
@@ -0,0 +1,44 @@
+package nes
+
+import (
+	"errors"
+	"io"
+)
+
+//ErrNoBattery is returned when battery RAM is saved or loaded on a cartridge without one.
+var ErrNoBattery = errors.New("nes: cartridge has no battery backed RAM")
+
+//batteryRAM returns the mapper's battery backed RAM, or nil if it has none.
+func (system *System) batteryRAM() []byte {
+	if battery, ok := system.memory.mapper.(BatteryBacked); ok {
+		return battery.BatteryRAM()
+	}
+	return nil
+}
+
+//HasBattery reports whether the inserted cartridge keeps its RAM across power cycles.
+func (system *System) HasBattery() bool {
+	return system.memory.mapper != nil && len(system.batteryRAM()) > 0
+}
+
+//SaveBattery writes the battery backed RAM to w in the usual .sav layout.
+func (system *System) SaveBattery(w io.Writer) error {
+	if !system.HasBattery() {
+		return ErrNoBattery
+	}
+	_, err := w.Write(system.batteryRAM())
+	return err
+}
+
+//LoadBattery restores battery backed RAM written by SaveBattery.
+//Files shorter than the RAM only fill its beginning.
+func (system *System) LoadBattery(r io.Reader) error {
+	if !system.HasBattery() {
+		return ErrNoBattery
+	}
+	_, err := io.ReadFull(r, system.batteryRAM())
+	if err == io.ErrUnexpectedEOF || err == io.EOF {
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,397 @@
+package nes
+
+import (
+	"crypto/sha1"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"io"
+)
+
+const (
+	headerSize     = 16
+	prgRomBankSize = 16384
+	chrRomBankSize = 8192
+	trainerSize    = 512
+	prgRAMBankSize = 8192
+)
+
+//Console types from the low bits of header byte 7.
+const (
+	consoleNES = iota
+	consoleVsSystem
+	consolePlaychoice
+	consoleExtended
+)
+
+//CPU/PPU timing modes from NES 2.0 header byte 12.
+const (
+	timingNTSC = iota
+	timingPAL
+	timingMultiRegion
+	timingDendy
+)
+
+var (
+	//ErrBadMagic is returned when the file does not start with the iNES signature.
+	ErrBadMagic = errors.New("nes: not a valid iNES file")
+	//ErrBadPRGSize is returned when the PRG rom is empty or not a whole number of 16KB banks.
+	ErrBadPRGSize = errors.New("nes: PRG rom size is not a non-zero multiple of 16KB")
+	//ErrBadCHRSize is returned when the CHR rom is not a whole number of 8KB banks.
+	ErrBadCHRSize = errors.New("nes: CHR rom size is not a multiple of 8KB")
+	//ErrTruncatedPRG is returned when the file is shorter than its declared PRG rom.
+	ErrTruncatedPRG = errors.New("nes: PRG rom is truncated")
+	//ErrTruncatedCHR is returned when the file is shorter than its declared CHR rom.
+	ErrTruncatedCHR = errors.New("nes: CHR rom is truncated")
+	//ErrNoCartridge is returned when the system is reset without a cartridge inserted.
+	ErrNoCartridge = errors.New("nes: no cartridge inserted")
+)
+
+//ErrUnsupportedMapper is returned when the cartridge uses a mapper we do not implement.
+type ErrUnsupportedMapper struct {
+	ID int
+}
+
+func (err ErrUnsupportedMapper) Error() string {
+	return fmt.Sprintf("nes: unsupported mapper %d", err.ID)
+}
+
+//Represents a iNES or NES 2.0 header. ROM and RAM sizes are in bytes.
+type iNES struct {
+	SizeRomPRG          int
+	SizeRomCHR          int
+	VerticalMirroring   bool
+	FourScreenMirroring bool
+	BatteryBacked       bool
+	TrainerExists       bool
+	IgnoreMirroring     bool
+	NES2                bool
+	MapperNumber        uint16
+	SubmapperNumber     uint8
+	SizeRAMPRG          int
+	SizeNVRAMPRG        int
+	SizeRAMCHR          int
+	SizeNVRAMCHR        int
+	ConsoleType         byte
+	TimingMode          byte
+	ExpansionDevice     byte
+	ExtraFlags          [7]byte
+}
+
+//Cartridge struct defines a Cartridge in memory.
+type Cartridge struct {
+	header     iNES
+	prg        []byte
+	chr        []byte
+	prgRAM     []byte
+	mapperID   uint16
+	mirrorMode int
+	//hash identifies the ROM contents for save states.
+	hash [sha1.Size]byte
+}
+
+func (system *System) resetCartridge() error {
+	if system.cartridge == nil {
+		return ErrNoCartridge
+	}
+	system.memory.cartridge = system.cartridge
+	//Load the mapper for our system.
+	mapper, err := system.ResetMapper()
+	if err != nil {
+		return err
+	}
+	system.memory.setMapper(mapper)
+	system.apu.expansion = system.memory.audio
+	return nil
+}
+
+//LoadROM reads a nes rom from r.
+func LoadROM(r io.Reader) (*Cartridge, error) {
+	nesFile, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	return LoadFromString(nesFile)
+}
+
+//LoadFromString loads a nes rom from a string.
+func LoadFromString(nesFile []byte) (*Cartridge, error) {
+	//Verify that the header is valid.
+	if err := assertCartridge(nesFile); err != nil {
+		return nil, err
+	}
+	headerSlice := nesFile[0:headerSize]
+	cartridge := &Cartridge{}
+	cartridge.isNES2(headerSlice)
+	cartridge.getPrgRomSize(headerSlice)
+	cartridge.getChrRomSize(headerSlice)
+	cartridge.isRomVerticalMirroring(headerSlice)
+	cartridge.isFourScreenMirroring(headerSlice)
+	cartridge.doesTrainerExist(headerSlice)
+	cartridge.isBatteryBacked(headerSlice)
+	cartridge.getMapperNumber(headerSlice)
+	cartridge.getRAMSizes(headerSlice)
+	cartridge.getConsoleType(headerSlice)
+	cartridge.getTimingMode(headerSlice)
+	cartridge.getExpansionDevice(headerSlice)
+	cartridge.getMirrorMode()
+	copy(cartridge.header.ExtraFlags[:], headerSlice[9:headerSize])
+	if _, ok := lookupMapper(cartridge.header.MapperNumber, cartridge.header.SubmapperNumber); !ok {
+		return nil, ErrUnsupportedMapper{ID: int(cartridge.header.MapperNumber)}
+	}
+	offset := headerSize
+
+	//Trainers are not supported.
+	if cartridge.header.TrainerExists {
+		offset += trainerSize
+	}
+
+	//Read in the PRG rom.
+	prgSize := cartridge.header.SizeRomPRG
+	if prgSize == 0 || prgSize%prgRomBankSize != 0 {
+		return nil, ErrBadPRGSize
+	}
+	if len(nesFile) < offset+prgSize {
+		return nil, ErrTruncatedPRG
+	}
+	cartridge.prg = nesFile[offset : offset+prgSize]
+	offset += prgSize
+	//Read in the CHR rom
+	if cartridge.header.SizeRomCHR != 0 {
+		chrSize := cartridge.header.SizeRomCHR
+		if chrSize%chrRomBankSize != 0 {
+			return nil, ErrBadCHRSize
+		}
+		if len(nesFile) < offset+chrSize {
+			return nil, ErrTruncatedCHR
+		}
+		cartridge.chr = nesFile[offset : offset+chrSize]
+		offset += chrSize
+	} else {
+		cartridge.chr = make([]byte, cartridge.chrRAMSize())
+	}
+	//PRG-RAM lives on the cartridge rather than in the mapper so that a reset keeps it.
+	cartridge.prgRAM = make([]byte, cartridge.prgRAMSize())
+	cartridge.hash = sha1.Sum(nesFile[headerSize:offset])
+	return cartridge, nil
+}
+
+//Save writes the cartridge's PRG-RAM and CHR-RAM, if it has any, and returns the first error.
+func (cartridge *Cartridge) Save(encoder *gob.Encoder) error {
+	if len(cartridge.prgRAM) > 0 {
+		if err := encoder.Encode(cartridge.prgRAM); err != nil {
+			return err
+		}
+	}
+	if cartridge.header.SizeRomCHR == 0 {
+		return encoder.Encode(cartridge.chr)
+	}
+	return nil
+}
+
+//Load reads back the RAM written by Save. The PRG-RAM is copied in place, as the mapper shares it.
+func (cartridge *Cartridge) Load(decoder *gob.Decoder) error {
+	if len(cartridge.prgRAM) > 0 {
+		var prgRAM []byte
+		if err := decoder.Decode(&prgRAM); err != nil {
+			return err
+		}
+		copy(cartridge.prgRAM, prgRAM)
+	}
+	if cartridge.header.SizeRomCHR == 0 {
+		return decoder.Decode(&cartridge.chr)
+	}
+	return nil
+}
+
+func assertCartridge(nesFile []byte) error {
+	if len(nesFile) < headerSize {
+		return ErrBadMagic
+	}
+
+	if string(nesFile[:3]) != "NES" || nesFile[3] != byte(0x1A) {
+		return ErrBadMagic
+	}
+	return nil
+}
+
+func (cartridge *Cartridge) isNES2(nesFile []byte) {
+	cartridge.header.NES2 = (nesFile[7] & 0x0C) == 0x08
+}
+
+func (cartridge *Cartridge) getPrgRomSize(nesFile []byte) {
+	if cartridge.header.NES2 {
+		cartridge.header.SizeRomPRG = nes2RomSize(nesFile[4], nesFile[9]&0x0F, prgRomBankSize)
+	} else {
+		cartridge.header.SizeRomPRG = int(nesFile[4]) * prgRomBankSize
+	}
+}
+
+func (cartridge *Cartridge) getChrRomSize(nesFile []byte) {
+	if cartridge.header.NES2 {
+		cartridge.header.SizeRomCHR = nes2RomSize(nesFile[5], nesFile[9]>>4, chrRomBankSize)
+	} else {
+		cartridge.header.SizeRomCHR = int(nesFile[5]) * chrRomBankSize
+	}
+}
+
+//nes2RomSize decodes a ROM size from its LSB byte and MSB nibble.
+//An MSB of $F selects the exponent-multiplier form, 2^E * (MM*2+1) bytes.
+func nes2RomSize(lsb byte, msb byte, bankSize int) int {
+	if msb != 0x0F {
+		return (int(msb)<<8 | int(lsb)) * bankSize
+	}
+	exponent := uint(lsb >> 2)
+	if exponent > 32 {
+		// Larger than any file we could have read; let the truncation check reject it.
+		exponent = 32
+	}
+	multiplier := int(lsb&0x03)*2 + 1
+	return (1 << exponent) * multiplier
+}
+
+//nes2RAMSize decodes a NES 2.0 shift count into a RAM size.
+func nes2RAMSize(shift byte) int {
+	if shift == 0 {
+		return 0
+	}
+	return 64 << shift
+}
+
+func (cartridge *Cartridge) isRomVerticalMirroring(nesFile []byte) {
+	cartridge.header.VerticalMirroring = (nesFile[6] & 0x01) != 0
+}
+
+func (cartridge *Cartridge) isBatteryBacked(nesFile []byte) {
+	cartridge.header.BatteryBacked = (nesFile[6] & 0x02) != 0
+}
+
+func (cartridge *Cartridge) doesTrainerExist(nesFile []byte) {
+	cartridge.header.TrainerExists = (nesFile[6] & 0x04) != 0
+}
+
+func (cartridge *Cartridge) isFourScreenMirroring(nesFile []byte) {
+	cartridge.header.FourScreenMirroring = (nesFile[6] & 0x08) != 0
+}
+
+//getMirrorMode picks the nametable layout soldered onto the board.
+func (cartridge *Cartridge) getMirrorMode() {
+	switch {
+	case cartridge.header.FourScreenMirroring:
+		cartridge.mirrorMode = MirrorFour
+	case cartridge.header.VerticalMirroring:
+		cartridge.mirrorMode = MirrorVertical
+	default:
+		cartridge.mirrorMode = MirrorHorizontal
+	}
+}
+
+func (cartridge *Cartridge) getMapperNumber(nesFile []byte) {
+	low := uint16(0xF0&nesFile[6]) >> 4
+	high := uint16(0xF0 & nesFile[7])
+	switch {
+	case cartridge.header.NES2:
+		cartridge.header.MapperNumber = uint16(nesFile[8]&0x0F)<<8 | high | low
+		cartridge.header.SubmapperNumber = nesFile[8] >> 4
+	case nesFile[12] != 0 || nesFile[13] != 0 || nesFile[14] != 0 || nesFile[15] != 0:
+		// Old dumping tools left text in bytes 7-15, so byte 7 can't be trusted.
+		cartridge.header.MapperNumber = low
+	default:
+		cartridge.header.MapperNumber = high | low
+	}
+}
+
+func (cartridge *Cartridge) getRAMSizes(nesFile []byte) {
+	if cartridge.header.NES2 {
+		cartridge.header.SizeRAMPRG = nes2RAMSize(nesFile[10] & 0x0F)
+		cartridge.header.SizeNVRAMPRG = nes2RAMSize(nesFile[10] >> 4)
+		cartridge.header.SizeRAMCHR = nes2RAMSize(nesFile[11] & 0x0F)
+		cartridge.header.SizeNVRAMCHR = nes2RAMSize(nesFile[11] >> 4)
+		return
+	}
+	// iNES counts PRG-RAM in 8KB units, with 0 meaning 8KB for compatibility.
+	size := int(nesFile[8]) * prgRAMBankSize
+	if size == 0 {
+		size = prgRAMBankSize
+	}
+	if cartridge.header.BatteryBacked {
+		cartridge.header.SizeNVRAMPRG = size
+	} else {
+		cartridge.header.SizeRAMPRG = size
+	}
+	if cartridge.header.SizeRomCHR == 0 {
+		cartridge.header.SizeRAMCHR = chrRomBankSize
+	}
+}
+
+func (cartridge *Cartridge) getConsoleType(nesFile []byte) {
+	cartridge.header.ConsoleType = nesFile[7] & 0x03
+}
+
+func (cartridge *Cartridge) getTimingMode(nesFile []byte) {
+	if cartridge.header.NES2 {
+		cartridge.header.TimingMode = nesFile[12] & 0x03
+	} else {
+		cartridge.header.TimingMode = nesFile[9] & 0x01
+	}
+}
+
+func (cartridge *Cartridge) getExpansionDevice(nesFile []byte) {
+	if cartridge.header.NES2 {
+		cartridge.header.ExpansionDevice = nesFile[15] & 0x3F
+	}
+}
+
+//prgRAMSize returns the size of the PRG-RAM, battery backed or not, on the board.
+func (cartridge *Cartridge) prgRAMSize() int {
+	return cartridge.header.SizeRAMPRG + cartridge.header.SizeNVRAMPRG
+}
+
+//chrRAMSize returns the size of the CHR-RAM, rounded up to whole 8KB banks and defaulting to 8KB when the header has none.
+func (cartridge *Cartridge) chrRAMSize() int {
+	size := cartridge.header.SizeRAMCHR + cartridge.header.SizeNVRAMCHR
+	if size == 0 {
+		return chrRomBankSize
+	}
+	return (size + chrRomBankSize - 1) / chrRomBankSize * chrRomBankSize
+}
+
+//PRG returns the PRG-ROM, for mappers registered outside this package.
+func (cartridge *Cartridge) PRG() []byte {
+	return cartridge.prg
+}
+
+//CHR returns the CHR-ROM, or the CHR-RAM when the board has no CHR-ROM.
+func (cartridge *Cartridge) CHR() []byte {
+	return cartridge.chr
+}
+
+//MapperNumber returns the iNES mapper number.
+func (cartridge *Cartridge) MapperNumber() uint16 {
+	return cartridge.header.MapperNumber
+}
+
+//SubmapperNumber returns the NES 2.0 submapper number, 0 for iNES 1.0 images.
+func (cartridge *Cartridge) SubmapperNumber() uint8 {
+	return cartridge.header.SubmapperNumber
+}
+
+//MirrorMode returns the nametable mirroring wired on the board.
+func (cartridge *Cartridge) MirrorMode() int {
+	return cartridge.mirrorMode
+}
+
+//PRGRAMSize returns the size of the PRG-RAM, battery backed or not.
+func (cartridge *Cartridge) PRGRAMSize() int {
+	return cartridge.prgRAMSize()
+}
+
+//PRGRAM returns the PRG-RAM, which the cartridge keeps across resets.
+func (cartridge *Cartridge) PRGRAM() []byte {
+	return cartridge.prgRAM
+}
+
+//BatteryBacked reports whether the header says the board has a battery.
+func (cartridge *Cartridge) BatteryBacked() bool {
+	return cartridge.header.BatteryBacked
+}
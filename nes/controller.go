@@ -0,0 +1,124 @@
+package nes
+
+import "encoding/gob"
+
+const (
+	//ButtonA on the gamepad.
+	ButtonA = iota
+	//ButtonB on the gamepad.
+	ButtonB
+	//ButtonSelect on the gamepad.
+	ButtonSelect
+	//ButtonStart on the gamepad.
+	ButtonStart
+	//ButtonUp on the gamepad.
+	ButtonUp
+	//ButtonDown on the gamepad.
+	ButtonDown
+	//ButtonLeft on the gamepad.
+	ButtonLeft
+	//ButtonRight on the gamepad.
+	ButtonRight
+)
+
+const (
+	//DefaultTurboRate is the autofire rate, in frames per press, until one is set.
+	DefaultTurboRate = 4
+	//MinTurboRate is the fastest autofire rate, pressed one frame and released the next.
+	MinTurboRate = 2
+	//MaxTurboRate is the slowest autofire rate.
+	MaxTurboRate = 255
+)
+
+//Controller represents a users controller.
+type Controller struct {
+	buttons [8]bool
+	//turbo holds the buttons that autofire while held.
+	turbo [8]bool
+	//turboRate is the autofire period in frames, 0 until set.
+	turboRate int
+	//turboFrame counts the frames a turbo button has been held, which keeps autofire in step with emulation.
+	turboFrame int
+	index      int
+	strobe     byte
+}
+
+func (system *System) resetControllers() {
+	for i := 0; i < len(system.controller); i++ {
+		system.controller[i].resetController()
+	}
+}
+
+func (controller *Controller) resetController() {
+	controller.turboFrame = 0
+}
+
+func (controller *Controller) Save(encoder *gob.Encoder) error {
+	return encodeAll(encoder,
+		controller.buttons,
+		controller.index,
+		controller.strobe,
+		controller.turbo,
+		controller.turboRate,
+		controller.turboFrame,
+	)
+}
+
+func (controller *Controller) Load(decoder *gob.Decoder) error {
+	return decodeAll(decoder,
+		&controller.buttons,
+		&controller.index,
+		&controller.strobe,
+		&controller.turbo,
+		&controller.turboRate,
+		&controller.turboFrame,
+	)
+}
+
+func (controller *Controller) rate() int {
+	if controller.turboRate == 0 {
+		return DefaultTurboRate
+	}
+	return controller.turboRate
+}
+
+//pressed reports whether the game sees button down this frame. Autofire buttons are down for the
+//first half of every turbo period, starting from the frame they were pressed.
+func (controller *Controller) pressed(button int) bool {
+	if controller.buttons[button] {
+		return true
+	}
+	rate := controller.rate()
+	return controller.turbo[button] && controller.turboFrame%rate < rate/2
+}
+
+//stepTurbo advances autofire by one emulated frame.
+func (controller *Controller) stepTurbo() {
+	if controller.turbo == [8]bool{} {
+		controller.turboFrame = 0
+		return
+	}
+	controller.turboFrame++
+}
+
+//Read shifts out the next button on D0. It is the same in either port.
+func (controller *Controller) Read(port int) byte {
+	data := byte(0)
+	if controller.index < 8 && controller.pressed(controller.index) {
+		data |= 1
+	}
+	if controller.strobe&1 == 1 {
+		controller.index = 0
+	} else {
+		controller.index++
+	}
+	return data
+}
+
+//Write latches the buttons while the strobe, bit 0, is high.
+func (controller *Controller) Write(data byte) {
+	controller.strobe = data
+	if controller.strobe&1 == 1 {
+		controller.index = 0
+	}
+}
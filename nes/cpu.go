@@ -0,0 +1,290 @@
+package nes
+
+import (
+	"encoding/gob"
+	"io"
+)
+
+const (
+	// NONE No interrupt is currently availible.
+	NONE = iota
+	// NMI Represents a non maskable interrupt.
+	NMI
+	// IRQ Represents a interrupt request.
+	IRQ
+)
+
+const (
+	resetVectorAddr = 0xFFFC
+	nmiVectorAddr   = 0xFFFA
+	brkVectorAddr   = 0xFFFE
+	defaultStackPtr = 0xFD
+)
+
+// CPU represents the NES CPU.
+type CPU struct {
+	memory      *Memory
+	accumulator byte
+	x           byte
+	y           byte
+	pc          uint16
+	sp          byte
+
+	ram *Memory
+
+	carry            bool
+	zero             bool
+	interruptEnabled bool
+	bcdEnabled       bool
+	overflow         bool
+	negative         bool
+
+	totalCycles      uint64
+	pendingInterrupt int
+	suspended        int
+
+	//cycles is how long the instruction being run takes. Taken branches add to it.
+	cycles int
+	//trace gets a line for every instruction run, if set.
+	trace io.Writer
+}
+
+func (system *System) resetCPU() {
+	system.cpu = CPU{
+		accumulator:      0,
+		x:                0,
+		y:                0,
+		pc:               0,
+		sp:               defaultStackPtr,
+		ram:              &system.memory,
+		trace:            system.cpu.trace,
+		carry:            false,
+		zero:             false,
+		interruptEnabled: true,
+		bcdEnabled:       false,
+		overflow:         false,
+		negative:         false,
+		totalCycles:      0,
+		pendingInterrupt: 0,
+		suspended:        0,
+	}
+}
+
+//Save writes the registers, flags and interrupt state and returns the first error.
+func (cpu *CPU) Save(encoder *gob.Encoder) error {
+	return encodeAll(encoder,
+		cpu.accumulator,
+		cpu.x,
+		cpu.y,
+		cpu.pc,
+		cpu.sp,
+		cpu.carry,
+		cpu.zero,
+		cpu.interruptEnabled,
+		cpu.bcdEnabled,
+		cpu.overflow,
+		cpu.negative,
+		cpu.totalCycles,
+		cpu.pendingInterrupt,
+		cpu.suspended,
+	)
+}
+
+//Load reads back the state written by Save.
+func (cpu *CPU) Load(decoder *gob.Decoder) error {
+	return decodeAll(decoder,
+		&cpu.accumulator,
+		&cpu.x,
+		&cpu.y,
+		&cpu.pc,
+		&cpu.sp,
+		&cpu.carry,
+		&cpu.zero,
+		&cpu.interruptEnabled,
+		&cpu.bcdEnabled,
+		&cpu.overflow,
+		&cpu.negative,
+		&cpu.totalCycles,
+		&cpu.pendingInterrupt,
+		&cpu.suspended,
+	)
+}
+
+func (cpu *CPU) getVectorReset() uint16 {
+	return uint16(cpu.ram.ReadUint16(resetVectorAddr))
+}
+
+func (cpu *CPU) getVectorNMI() uint16 {
+	return uint16(cpu.ram.ReadUint16(nmiVectorAddr))
+}
+
+func (cpu *CPU) getVectorBRK() uint16 {
+	return uint16(cpu.ram.ReadUint16(brkVectorAddr))
+}
+
+//readUint16Bugged reads a pointer the way the 6502 does for indirect addressing, without
+//carrying into the high byte: a pointer at $xxFF takes its high byte from $xx00.
+func (cpu *CPU) readUint16Bugged(addr uint16) uint16 {
+	low := cpu.ram.ReadByte(addr)
+	high := cpu.ram.ReadByte((addr & 0xFF00) | uint16(byte(addr)+1))
+	return uint16(high)<<8 | uint16(low)
+}
+
+func (cpu *CPU) stackPush(data byte) {
+	cpu.ram.WriteByte(uint16(0x0100+uint16(cpu.sp)), data)
+	cpu.sp--
+}
+
+func (cpu *CPU) stackPull() byte {
+	cpu.sp++
+	return cpu.ram.ReadByte(uint16(0x0100 + uint16(cpu.sp)))
+}
+
+func (cpu *CPU) statusPack(bFlag bool) (data byte) {
+	if cpu.carry {
+		data |= 1 << 0
+	}
+	if cpu.zero {
+		data |= 1 << 1
+	}
+	if cpu.interruptEnabled {
+		data |= 1 << 2
+	}
+	if cpu.bcdEnabled {
+		data |= 1 << 3
+	}
+	if bFlag {
+		data |= 1 << 4
+	}
+	data |= 1 << 5
+	if cpu.overflow {
+		data |= 1 << 6
+	}
+	if cpu.negative {
+		data |= 1 << 7
+	}
+	return
+}
+
+func (cpu *CPU) statusUnpack(data byte) {
+	cpu.carry = data&(1<<0) > 0
+	cpu.zero = data&(1<<1) > 0
+	cpu.interruptEnabled = data&(1<<2) > 0
+	cpu.bcdEnabled = data&(1<<3) > 0
+	cpu.overflow = data&(1<<6) > 0
+	cpu.negative = data&(1<<7) > 0
+}
+
+func (cpu *CPU) handleInterrupt(addr uint16) {
+	cpu.stackPush(byte((cpu.pc >> 8) & 0xFF))
+	cpu.stackPush(byte(cpu.pc & 0xFF))
+	cpu.stackPush(cpu.statusPack(false))
+	cpu.interruptEnabled = true
+	cpu.pc = addr
+	cpu.pendingInterrupt = NONE
+}
+
+func (cpu *CPU) triggerInterruptNMI() {
+	cpu.pendingInterrupt = NMI
+}
+
+func (cpu *CPU) triggerInterruptIRQ() {
+	if !cpu.interruptEnabled && cpu.pendingInterrupt == NONE {
+		cpu.pendingInterrupt = IRQ
+	}
+}
+
+//handleInterrupts starts a pending interrupt, returning the cycles it took.
+func (cpu *CPU) handleInterrupts() int {
+	switch cpu.pendingInterrupt {
+	case NMI:
+		cpu.handleInterrupt(cpu.getVectorNMI())
+	case IRQ:
+		// An SEI since the IRQ was raised masks it.
+		if cpu.interruptEnabled {
+			cpu.pendingInterrupt = NONE
+			return 0
+		}
+		cpu.handleInterrupt(cpu.getVectorBRK())
+	}
+	return 7
+}
+
+//operand works out the address an instruction at pc operates on and whether indexing
+//crossed a page. Crossing a page first reads the address before the carry into the high byte.
+func (cpu *CPU) operand(mode addressMode) (uint16, bool) {
+	switch mode {
+	case modeImmediate:
+		return cpu.pc + 1, false
+	case modeZeroPage:
+		return uint16(cpu.ram.ReadByte(cpu.pc + 1)), false
+	case modeZeroPageX:
+		return uint16(cpu.ram.ReadByte(cpu.pc+1) + cpu.x), false
+	case modeZeroPageY:
+		return uint16(cpu.ram.ReadByte(cpu.pc+1) + cpu.y), false
+	case modeRelative:
+		offset := int8(cpu.ram.ReadByte(cpu.pc + 1))
+		return cpu.pc + 2 + uint16(offset), false
+	case modeAbsolute:
+		return cpu.ram.ReadUint16(cpu.pc + 1), false
+	case modeAbsoluteX:
+		return cpu.indexed(cpu.ram.ReadUint16(cpu.pc+1), cpu.x)
+	case modeAbsoluteY:
+		return cpu.indexed(cpu.ram.ReadUint16(cpu.pc+1), cpu.y)
+	case modeIndirect:
+		return cpu.readUint16Bugged(cpu.ram.ReadUint16(cpu.pc + 1)), false
+	case modeIndirectX:
+		return cpu.readUint16Bugged(uint16(cpu.ram.ReadByte(cpu.pc+1) + cpu.x)), false
+	case modeIndirectY:
+		return cpu.indexed(cpu.readUint16Bugged(uint16(cpu.ram.ReadByte(cpu.pc+1))), cpu.y)
+	}
+	return 0, false
+}
+
+func (cpu *CPU) indexed(base uint16, index byte) (uint16, bool) {
+	addr := base + uint16(index)
+	if addr&0xFF00 != base&0xFF00 {
+		cpu.ram.ReadByte(base&0xFF00 | addr&0x00FF)
+		return addr, true
+	}
+	return addr, false
+}
+
+//step runs the instruction at pc and returns the cycles it took.
+func (cpu *CPU) step() int {
+	op := &opcodes[cpu.ram.ReadByte(cpu.pc)]
+	addr, pageCrossed := cpu.operand(op.mode)
+	cpu.pc += uint16(op.mode.size())
+	cpu.cycles = op.cycles
+	if pageCrossed {
+		cpu.cycles += op.pageCycles
+	}
+	op.execute(cpu, addr)
+	return cpu.cycles
+}
+
+//Emulate emulates the CPU for a number of cycles, returning the amount of cycles emulated.
+func (cpu *CPU) Emulate(cycles int) int {
+	//See how many cycles we have to emulate.
+	cyclesLeft := cycles
+	//Emulate while cycles left is not zero.
+	for cyclesLeft > 0 {
+		switch {
+		case cpu.suspended > 0:
+			//DMA has the bus, so the CPU waits a cycle.
+			cpu.suspended--
+			cyclesLeft--
+		case cpu.pendingInterrupt != NONE:
+			cyclesLeft -= cpu.handleInterrupts()
+		default:
+			if cpu.trace != nil {
+				cpu.traceInstruction()
+			}
+			cyclesLeft -= cpu.step()
+		}
+	}
+	//Return how many cycles we emulated.
+	cyclesThisTick := cycles - cyclesLeft
+	cpu.totalCycles += uint64(cyclesThisTick)
+	return cyclesThisTick
+}
@@ -0,0 +1,95 @@
+package nes
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+//peekByte reads memory for the debugger without disturbing anything. Only RAM and the
+//cartridge from $6000 up are read; the registers and the expansion area in between, which a
+//read can change, are shown as zero.
+func (memory *Memory) peekByte(address uint16) byte {
+	switch {
+	case address <= 0x1FFF:
+		return memory.RAM[address&0x07FF]
+	case address >= 0x6000:
+		return memory.mapper.ReadByte(address)
+	}
+	return 0
+}
+
+//disassemble returns the instruction at address as assembly, its bytes and the address of the next instruction.
+func (memory *Memory) disassemble(address uint16) (string, []byte, uint16) {
+	op := &opcodes[memory.peekByte(address)]
+	code := make([]byte, op.mode.size())
+	for i := range code {
+		code[i] = memory.peekByte(address + uint16(i))
+	}
+	var operand uint16
+	switch len(code) {
+	case 2:
+		operand = uint16(code[1])
+	case 3:
+		operand = uint16(code[1]) | uint16(code[2])<<8
+	}
+	next := address + uint16(len(code))
+
+	var text string
+	switch op.mode {
+	case modeImplied:
+		text = op.mnemonic
+	case modeAccumulator:
+		text = op.mnemonic + " A"
+	case modeImmediate:
+		text = fmt.Sprintf("%s #$%02X", op.mnemonic, operand)
+	case modeZeroPage:
+		text = fmt.Sprintf("%s $%02X", op.mnemonic, operand)
+	case modeZeroPageX:
+		text = fmt.Sprintf("%s $%02X,X", op.mnemonic, operand)
+	case modeZeroPageY:
+		text = fmt.Sprintf("%s $%02X,Y", op.mnemonic, operand)
+	case modeRelative:
+		text = fmt.Sprintf("%s $%04X", op.mnemonic, next+uint16(int8(operand)))
+	case modeAbsolute:
+		text = fmt.Sprintf("%s $%04X", op.mnemonic, operand)
+	case modeAbsoluteX:
+		text = fmt.Sprintf("%s $%04X,X", op.mnemonic, operand)
+	case modeAbsoluteY:
+		text = fmt.Sprintf("%s $%04X,Y", op.mnemonic, operand)
+	case modeIndirect:
+		text = fmt.Sprintf("%s ($%04X)", op.mnemonic, operand)
+	case modeIndirectX:
+		text = fmt.Sprintf("%s ($%02X,X)", op.mnemonic, operand)
+	case modeIndirectY:
+		text = fmt.Sprintf("%s ($%02X),Y", op.mnemonic, operand)
+	}
+	if op.unofficial {
+		text = "*" + text
+	}
+	return text, code, next
+}
+
+//Disassemble returns the instruction at address as assembly and the address of the instruction after it.
+//Unofficial opcodes are marked with a *.
+func (system *System) Disassemble(address uint16) (string, uint16) {
+	text, _, next := system.memory.disassemble(address)
+	return text, next
+}
+
+//SetTrace writes a line to w for every instruction the CPU runs from now on, in the style of
+//nestest.log: the address, the instruction's bytes and assembly, then the registers and the
+//cycle count before it runs. nil stops tracing. Write errors are ignored.
+func (system *System) SetTrace(w io.Writer) {
+	system.cpu.trace = w
+}
+
+func (cpu *CPU) traceInstruction() {
+	text, code, _ := cpu.ram.disassemble(cpu.pc)
+	hex := make([]string, len(code))
+	for i, b := range code {
+		hex[i] = fmt.Sprintf("%02X", b)
+	}
+	fmt.Fprintf(cpu.trace, "%04X  %-8s  %-31s A:%02X X:%02X Y:%02X P:%02X SP:%02X CYC:%d\n",
+		cpu.pc, strings.Join(hex, " "), text, cpu.accumulator, cpu.x, cpu.y, cpu.statusPack(false), cpu.sp, cpu.totalCycles)
+}
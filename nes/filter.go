@@ -0,0 +1,139 @@
+package nes
+
+import "math"
+
+type Filter interface {
+	Emulate(x float32) float32
+}
+
+// First order filters are defined by the following parameters.
+// y[n] = B0*x[n] + B1*x[n-1] - A1*y[n-1]
+type FirstOrderFilter struct {
+	B0    float32
+	B1    float32
+	A1    float32
+	prevX float32
+	prevY float32
+}
+
+func (f *FirstOrderFilter) Emulate(x float32) float32 {
+	y := f.B0*x + f.B1*f.prevX - f.A1*f.prevY
+	f.prevY = y
+	f.prevX = x
+	return y
+}
+
+// prewarp returns the bilinear transform's frequency scale, chosen so the
+// digital filter's cutoff lands exactly on cutoffFreq.
+func prewarp(sampleRate float32, cutoffFreq float32) float32 {
+	return float32(1 / math.Tan(math.Pi*float64(cutoffFreq)/float64(sampleRate)))
+}
+
+// sampleRate: samples per second
+// cutoffFreq: oscillations per second
+func LowPassFilter(sampleRate float32, cutoffFreq float32) Filter {
+	c := prewarp(sampleRate, cutoffFreq)
+	a0i := 1 / (1 + c)
+	return &FirstOrderFilter{
+		B0: a0i,
+		B1: a0i,
+		A1: (1 - c) * a0i,
+	}
+}
+
+func HighPassFilter(sampleRate float32, cutoffFreq float32) Filter {
+	c := prewarp(sampleRate, cutoffFreq)
+	a0i := 1 / (1 + c)
+	return &FirstOrderFilter{
+		B0: c * a0i,
+		B1: -c * a0i,
+		A1: (1 - c) * a0i,
+	}
+}
+
+// Second order filters are defined by the following parameters.
+// y[n] = B0*x[n] + B1*x[n-1] + B2*x[n-2] - A1*y[n-1] - A2*y[n-2]
+type BiquadFilter struct {
+	B0    float32
+	B1    float32
+	B2    float32
+	A1    float32
+	A2    float32
+	prevX [2]float32
+	prevY [2]float32
+}
+
+func (f *BiquadFilter) Emulate(x float32) float32 {
+	y := f.B0*x + f.B1*f.prevX[0] + f.B2*f.prevX[1] - f.A1*f.prevY[0] - f.A2*f.prevY[1]
+	f.prevX[1], f.prevX[0] = f.prevX[0], x
+	f.prevY[1], f.prevY[0] = f.prevY[0], y
+	return y
+}
+
+// biquad normalizes a filter from the Audio EQ Cookbook by a0.
+func biquad(b0, b1, b2, a0, a1, a2 float64) *BiquadFilter {
+	return &BiquadFilter{
+		B0: float32(b0 / a0),
+		B1: float32(b1 / a0),
+		B2: float32(b2 / a0),
+		A1: float32(a1 / a0),
+		A2: float32(a2 / a0),
+	}
+}
+
+// BiquadLowPass is a second order low-pass. A q of 1/sqrt(2) gives a Butterworth response.
+func BiquadLowPass(sampleRate float32, cutoffFreq float32, q float32) Filter {
+	w := 2 * math.Pi * float64(cutoffFreq) / float64(sampleRate)
+	alpha := math.Sin(w) / (2 * float64(q))
+	cos := math.Cos(w)
+	return biquad((1-cos)/2, 1-cos, (1-cos)/2, 1+alpha, -2*cos, 1-alpha)
+}
+
+// BiquadHighPass is a second order high-pass. A q of 1/sqrt(2) gives a Butterworth response.
+func BiquadHighPass(sampleRate float32, cutoffFreq float32, q float32) Filter {
+	w := 2 * math.Pi * float64(cutoffFreq) / float64(sampleRate)
+	alpha := math.Sin(w) / (2 * float64(q))
+	cos := math.Cos(w)
+	return biquad((1+cos)/2, -(1 + cos), (1+cos)/2, 1+alpha, -2*cos, 1-alpha)
+}
+
+type FilterChain []Filter
+
+func (fc FilterChain) Emulate(x float32) float32 {
+	if fc != nil {
+		for i := range fc {
+			x = fc[i].Emulate(x)
+		}
+	}
+	return x
+}
+
+// FilterType picks the analog output stage the APU's output goes through.
+type FilterType int
+
+const (
+	// FilterNES is the front loading NES: high-pass at 90Hz and 440Hz and low-pass at 14kHz.
+	FilterNES FilterType = iota
+	// FilterFamicom is the Famicom, which only has a 37Hz high-pass before the 14kHz low-pass.
+	FilterFamicom
+	// FilterNone leaves the DAC output as it is, for analysis.
+	FilterNone
+)
+
+// NewFilterChain builds the output filters for a sample rate. Cutoffs at or above
+// Nyquist are left out, since the resampler has already removed everything up there.
+func NewFilterChain(filter FilterType, sampleRate float32) FilterChain {
+	var chain FilterChain
+	switch filter {
+	case FilterNES:
+		chain = append(chain, HighPassFilter(sampleRate, 90), HighPassFilter(sampleRate, 440))
+	case FilterFamicom:
+		chain = append(chain, HighPassFilter(sampleRate, 37))
+	default:
+		return nil
+	}
+	if 14000 < sampleRate/2 {
+		chain = append(chain, LowPassFilter(sampleRate, 14000))
+	}
+	return chain
+}
@@ -0,0 +1,163 @@
+package nes
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+var (
+	//ErrFM2State is returned for FM2 movies that start from a save state, which only FCEUX can load.
+	ErrFM2State = errors.New("nes: FM2 movies must start at power on")
+	//ErrFM2Input is returned for movies with input FM2 has no way to record.
+	ErrFM2Input = errors.New("nes: FM2 movies can't hold this input")
+)
+
+//fm2Buttons is the order FCEUX writes gamepad buttons in, left to right.
+var fm2Buttons = [8]int{ButtonRight, ButtonLeft, ButtonDown, ButtonUp, ButtonStart, ButtonSelect, ButtonB, ButtonA}
+
+const (
+	fm2SoftReset = 1
+	fm2HardReset = 2
+)
+
+//WriteFM2 writes movie in FCEUX's text movie format. FCEUX has no turbo in its movies, so
+//autofire is written out as the presses it made.
+func WriteFM2(w io.Writer, movie *Movie) error {
+	if movie.State != nil {
+		return ErrFM2State
+	}
+	if movie.Multitap == MultitapFamicom || movie.Devices != [ExpansionPort + 1]string{} {
+		return ErrFM2Input
+	}
+	fourScore := 0
+	if movie.Multitap == MultitapFourScore {
+		fourScore = 1
+	}
+	writer := bufio.NewWriter(w)
+	fmt.Fprintln(writer, "version 3")
+	fmt.Fprintln(writer, "emuVersion 22020")
+	fmt.Fprintln(writer, "rerecordCount 0")
+	fmt.Fprintln(writer, "palFlag 0")
+	fmt.Fprintf(writer, "fourscore %d\n", fourScore)
+	fmt.Fprintln(writer, "port0 1")
+	fmt.Fprintln(writer, "port1 1")
+	fmt.Fprintln(writer, "port2 0")
+	pressed := pressedButtons(movie)
+	for i, frame := range movie.Frames {
+		command := 0
+		if frame.Reset {
+			command |= fm2SoftReset
+		}
+		if frame.PowerCycle {
+			command |= fm2HardReset
+		}
+		fmt.Fprintf(writer, "|%d|", command)
+		for player := 0; player < movie.players(); player++ {
+			fmt.Fprintf(writer, "%s|", formatFM2Buttons(pressed[i][player]))
+		}
+		fmt.Fprintln(writer, "|")
+	}
+	return writer.Flush()
+}
+
+//ReadFM2 reads an FCEUX text movie recorded with standard controllers, optionally through a Four Score.
+func ReadFM2(r io.Reader) (*Movie, error) {
+	movie := &Movie{}
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if strings.HasPrefix(line, "|") {
+			frame, err := parseFM2Frame(line, movie.players())
+			if err != nil {
+				return nil, err
+			}
+			movie.Frames = append(movie.Frames, frame)
+			continue
+		}
+
+		key, value := line, ""
+		if space := strings.IndexByte(line, ' '); space >= 0 {
+			key, value = line[:space], line[space+1:]
+		}
+		switch key {
+		case "binary", "port2":
+			// Only the text input log with plain controllers is supported.
+			if value != "0" {
+				return nil, ErrBadMovie
+			}
+		case "fourscore":
+			switch value {
+			case "0":
+				movie.Multitap = MultitapNone
+			case "1":
+				movie.Multitap = MultitapFourScore
+			default:
+				return nil, ErrBadMovie
+			}
+		case "port0", "port1":
+			// 0 is an empty port and 1 a gamepad.
+			if value != "0" && value != "1" {
+				return nil, ErrBadMovie
+			}
+		case "savestate":
+			if value != "" {
+				return nil, ErrFM2State
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return movie, nil
+}
+
+func parseFM2Frame(line string, players int) (MovieFrame, error) {
+	var frame MovieFrame
+	fields := strings.Split(line, "|")
+	if len(fields) < 2+players {
+		return frame, ErrBadMovie
+	}
+	command, err := strconv.Atoi(fields[1])
+	if err != nil {
+		return frame, ErrBadMovie
+	}
+	// FCEUX's hard reset is a power cycle.
+	frame.Reset = command&fm2SoftReset != 0
+	frame.PowerCycle = command&fm2HardReset != 0
+	for port := 0; port < players; port++ {
+		frame.Buttons[port], err = parseFM2Buttons(fields[2+port])
+		if err != nil {
+			return frame, err
+		}
+	}
+	return frame, nil
+}
+
+func parseFM2Buttons(field string) (byte, error) {
+	if field == "" {
+		return 0, nil
+	}
+	if len(field) != len(fm2Buttons) {
+		return 0, ErrBadMovie
+	}
+	var buttons [8]bool
+	for i, button := range fm2Buttons {
+		buttons[button] = field[i] != '.' && field[i] != ' '
+	}
+	return packButtons(buttons), nil
+}
+
+func formatFM2Buttons(packed byte) string {
+	buttons := unpackButtons(packed)
+	field := []byte("RLDUTSBA")
+	for i, button := range fm2Buttons {
+		if !buttons[button] {
+			field[i] = '.'
+		}
+	}
+	return string(field)
+}
@@ -0,0 +1,103 @@
+package nes
+
+//ExpansionPort is the port number of the Famicom expansion port, after the two controller ports.
+const ExpansionPort = 2
+
+//InputDevice is anything plugged into a controller port or the Famicom expansion port.
+//Every device sees every write to $4016. A device in a controller port is read through that
+//port's register, $4016 or $4017; a device in the expansion port is read through both.
+type InputDevice interface {
+	//Read returns what the device drives onto D0-D4 for a read of $4016 (port 0) or $4017 (port 1).
+	//Only the low five bits are used. Reading is what clocks shift registers along.
+	Read(port int) byte
+	//Write receives the OUT0-OUT2 pins, the low three bits of a write to $4016.
+	//OUT0 is the strobe that latches controllers.
+	Write(out byte)
+}
+
+//MovieInputSize is the most input, in bytes, a RecordedDevice can record for a frame.
+const MovieInputSize = 16
+
+//RecordedDevice is an InputDevice whose input movies record along with the controllers.
+type RecordedDevice interface {
+	InputDevice
+	//MovieName identifies the kind of device, so playback can check the same kind is plugged in.
+	MovieName() string
+	//MovieInput stores the input the device will give during the coming frame.
+	MovieInput(input *[MovieInputSize]byte)
+	//SetMovieInput plays back input stored by MovieInput.
+	SetMovieInput(input *[MovieInputSize]byte)
+}
+
+//inputPorts holds what is plugged into the console. An empty port reads as nothing pressed.
+type inputPorts struct {
+	devices [ExpansionPort + 1]InputDevice
+	//sensors are the plugged in devices that watch the picture.
+	sensors []lightSensor
+}
+
+func (ports *inputPorts) write(value byte) {
+	for _, device := range ports.devices {
+		if device != nil {
+			device.Write(value & 0x07)
+		}
+	}
+}
+
+func (ports *inputPorts) read(port int) byte {
+	var data byte
+	if device := ports.devices[port]; device != nil {
+		data |= device.Read(port)
+	}
+	if device := ports.devices[ExpansionPort]; device != nil {
+		data |= device.Read(port)
+	}
+	// D5-D7 aren't driven, so they keep the high byte of the address, $40.
+	return 0x40 | data&0x1F
+}
+
+//SetInputDevice plugs device into controller port 0 or 1 or into ExpansionPort. nil empties the port.
+//The ports start with the standard controllers set through SetButtons.
+func (system *System) SetInputDevice(port int, device InputDevice) {
+	system.input.devices[port] = device
+	system.input.sensors = nil
+	for _, device := range system.input.devices {
+		if sensor, ok := device.(lightSensor); ok {
+			sensor.attachPPU(&system.ppu)
+			system.input.sensors = append(system.input.sensors, sensor)
+		}
+	}
+}
+
+//InputDevice returns what is plugged into a port.
+func (system *System) InputDevice(port int) InputDevice {
+	return system.input.devices[port]
+}
+
+//inputDeviceParts returns the devices that have state, other than the standard controllers which are always saved.
+func (system *System) inputDeviceParts() []StateSaver {
+	var parts []StateSaver
+	for port, device := range system.input.devices {
+		if saver, ok := device.(StateSaver); ok && system.savesInputDevice(port) {
+			parts = append(parts, saver)
+		}
+	}
+	return parts
+}
+
+//savesInputDevice reports whether the device in port is saved with it: it isn't a standard
+//controller and, like a Four Score, wasn't already saved with an earlier port.
+func (system *System) savesInputDevice(port int) bool {
+	device := system.input.devices[port]
+	for i := range system.controller {
+		if device == InputDevice(&system.controller[i]) {
+			return false
+		}
+	}
+	for _, earlier := range system.input.devices[:port] {
+		if device == earlier {
+			return false
+		}
+	}
+	return true
+}
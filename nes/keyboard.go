@@ -0,0 +1,177 @@
+package nes
+
+import "encoding/gob"
+
+//Keys of the Family BASIC keyboard, numbered by their place in its matrix: eight keys to a row,
+//four in column 0 and then four in column 1, each column read on D1-D4 of $4017.
+const (
+	// Row 0.
+	KeyRightBracket = iota
+	KeyLeftBracket
+	KeyReturn
+	KeyF8
+	KeyStop
+	KeyYen
+	KeyRightShift
+	KeyKana
+	// Row 1.
+	KeySemicolon
+	KeyColon
+	KeyAt
+	KeyF7
+	KeyCaret
+	KeyMinus
+	KeySlash
+	KeyUnderscore
+	// Row 2.
+	KeyK
+	KeyL
+	KeyO
+	KeyF6
+	Key0
+	KeyP
+	KeyComma
+	KeyPeriod
+	// Row 3.
+	KeyJ
+	KeyU
+	KeyI
+	KeyF5
+	Key8
+	Key9
+	KeyN
+	KeyM
+	// Row 4.
+	KeyH
+	KeyG
+	KeyY
+	KeyF4
+	Key6
+	Key7
+	KeyV
+	KeyB
+	// Row 5.
+	KeyD
+	KeyR
+	KeyT
+	KeyF3
+	Key4
+	Key5
+	KeyC
+	KeyF
+	// Row 6.
+	KeyA
+	KeyS
+	KeyW
+	KeyF2
+	Key3
+	KeyE
+	KeyZ
+	KeyX
+	// Row 7.
+	KeyCtrl
+	KeyQ
+	KeyEscape
+	KeyF1
+	Key2
+	Key1
+	KeyGraph
+	KeyLeftShift
+	// Row 8.
+	KeyLeft
+	KeyRight
+	KeyUp
+	KeyClearHome
+	KeyInsert
+	KeyDelete
+	KeySpace
+	KeyDown
+	//FamilyKeyCount is the number of keys on the keyboard.
+	FamilyKeyCount
+)
+
+//familyKeyboardRows is the number of rows the keyboard steps through. The last has no keys,
+//which is how software tells the keyboard is there.
+const familyKeyboardRows = FamilyKeyCount/8 + 1
+
+//FamilyKeyboard is the Family BASIC keyboard, plugged into the Famicom expansion port.
+//Writes to $4016 enable it (OUT2), pick a column (OUT1, moving to the next row when it goes
+//from 1 to 0) and go back to the first row (OUT0). Reads of $4017 return the four keys of the
+//picked row and column on D1-D4, low for held.
+type FamilyKeyboard struct {
+	//keys holds a bit for every key, a byte to a row.
+	keys    [FamilyKeyCount / 8]byte
+	row     int
+	column  int
+	enabled bool
+}
+
+//NewFamilyKeyboard returns a keyboard with no keys held.
+func NewFamilyKeyboard() *FamilyKeyboard {
+	return &FamilyKeyboard{}
+}
+
+//SetKey presses or releases one of the Key constants.
+func (keyboard *FamilyKeyboard) SetKey(key int, pressed bool) {
+	if pressed {
+		keyboard.keys[key/8] |= 1 << uint(key%8)
+	} else {
+		keyboard.keys[key/8] &^= 1 << uint(key%8)
+	}
+}
+
+//ReleaseKeys lets go of every key.
+func (keyboard *FamilyKeyboard) ReleaseKeys() {
+	keyboard.keys = [FamilyKeyCount / 8]byte{}
+}
+
+//Read returns the picked keys on D1-D4 of $4017. Nothing is driven onto $4016 or while disabled.
+func (keyboard *FamilyKeyboard) Read(port int) byte {
+	if port != 1 || !keyboard.enabled {
+		return 0
+	}
+	var held byte
+	if keyboard.row < len(keyboard.keys) {
+		held = keyboard.keys[keyboard.row] >> uint(keyboard.column*4)
+	}
+	return ^held << 1 & 0x1E
+}
+
+func (keyboard *FamilyKeyboard) Write(out byte) {
+	column := int(out>>1) & 1
+	keyboard.enabled = out&0x04 != 0
+	if !keyboard.enabled {
+		keyboard.column = column
+		return
+	}
+	if keyboard.column == 1 && column == 0 {
+		keyboard.row = (keyboard.row + 1) % familyKeyboardRows
+	}
+	keyboard.column = column
+	if out&1 == 1 {
+		keyboard.row = 0
+	}
+}
+
+func (keyboard *FamilyKeyboard) Save(encoder *gob.Encoder) error {
+	return encodeAll(encoder, keyboard.row, keyboard.column, keyboard.enabled)
+}
+
+func (keyboard *FamilyKeyboard) Load(decoder *gob.Decoder) error {
+	return decodeAll(decoder, &keyboard.row, &keyboard.column, &keyboard.enabled)
+}
+
+//MovieName identifies the keyboard in movies.
+func (keyboard *FamilyKeyboard) MovieName() string {
+	return "family-keyboard"
+}
+
+//MovieInput records the keys held, a byte to a row.
+func (keyboard *FamilyKeyboard) MovieInput(input *[MovieInputSize]byte) {
+	copy(input[:], keyboard.keys[:])
+}
+
+//SetMovieInput plays back input recorded by MovieInput.
+func (keyboard *FamilyKeyboard) SetMovieInput(input *[MovieInputSize]byte) {
+	copy(keyboard.keys[:], input[:])
+}
@@ -0,0 +1,144 @@
+package nes
+
+import (
+	"encoding/gob"
+	"sync"
+)
+
+//TODO: this is also defined in cartridge, maybe reuse?
+const (
+	MirrorHorizontal = iota
+	MirrorVertical
+	MirrorSingleA
+	MirrorSingleB
+	MirrorFour
+)
+
+//MirrorLookup TODO: Figure out why this is needed.
+var MirrorLookup = [][4]int{
+	{0, 0, 1, 1},
+	{0, 1, 0, 1},
+	{0, 0, 0, 0},
+	{1, 1, 1, 1},
+	{0, 1, 2, 3},
+}
+
+//Mapper interface defines the functionality of a memory mapper.
+//Mappers may also implement any of the optional capability interfaces below.
+type Mapper interface {
+	ReadByte(address uint16) byte
+	WriteByte(address uint16, value byte)
+	Emulate()
+}
+
+//StateSaver is implemented by mappers with registers or RAM that belong in save states.
+type StateSaver interface {
+	Save(encoder *gob.Encoder) error
+	Load(decoder *gob.Decoder) error
+}
+
+//IRQSource is implemented by mappers that can interrupt the CPU.
+//The IRQ line is level triggered, so it stays asserted until the game acknowledges it.
+type IRQSource interface {
+	IRQPending() bool
+}
+
+//PPUBusObserver is implemented by mappers that watch the addresses the PPU puts on its bus,
+//for example to count scanlines from A12.
+type PPUBusObserver interface {
+	ObservePPUBus(address uint16)
+}
+
+//AudioExpansion is implemented by mappers with their own sound hardware, like the VRC6 or the FDS.
+//The chip's state is saved separately from the board's, so a chip can be shared between boards.
+type AudioExpansion interface {
+	//StepAudio is clocked by the APU once per CPU cycle.
+	StepAudio()
+	//AudioOutput returns the chip's current level in the units of the APU's mixed output,
+	//where a pulse channel at full volume measures ExpansionPulseLevel. It is added to the
+	//APU's output after its DACs, the way the cartridge mixes it on the console.
+	AudioOutput() float32
+	SaveAudio(encoder *gob.Encoder) error
+	LoadAudio(decoder *gob.Decoder) error
+}
+
+//BatteryBacked is implemented by mappers whose RAM is kept alive by a battery when the power is off.
+type BatteryBacked interface {
+	//BatteryRAM returns the live battery backed RAM, or nil if the board has none.
+	BatteryRAM() []byte
+}
+
+//MapperConstructor builds a mapper for the cartridge in memory.
+type MapperConstructor func(memory *Memory) Mapper
+
+//AnySubmapper registers a constructor for every submapper without one of its own.
+const AnySubmapper = -1
+
+type mapperKey struct {
+	id        uint16
+	submapper int
+}
+
+var mapperRegistry = struct {
+	sync.RWMutex
+	constructors map[mapperKey]MapperConstructor
+}{constructors: map[mapperKey]MapperConstructor{}}
+
+func init() {
+	RegisterMapper(0, AnySubmapper, func(memory *Memory) Mapper { return memory.resetMapper0() })
+	RegisterMapper(1, AnySubmapper, func(memory *Memory) Mapper { return memory.resetMapperMMC1() })
+	RegisterMapper(3, AnySubmapper, func(memory *Memory) Mapper { return memory.resetMapper3() })
+	RegisterMapper(4, AnySubmapper, func(memory *Memory) Mapper { return memory.resetMapperMMC3() })
+}
+
+//RegisterMapper makes a mapper available for an iNES mapper number and NES 2.0 submapper.
+//Registering the same number again replaces the previous constructor.
+func RegisterMapper(id uint16, submapper int, constructor MapperConstructor) {
+	mapperRegistry.Lock()
+	defer mapperRegistry.Unlock()
+	mapperRegistry.constructors[mapperKey{id, submapper}] = constructor
+}
+
+//lookupMapper finds the constructor for a mapper, preferring an exact submapper match.
+func lookupMapper(id uint16, submapper uint8) (MapperConstructor, bool) {
+	mapperRegistry.RLock()
+	defer mapperRegistry.RUnlock()
+	if constructor, ok := mapperRegistry.constructors[mapperKey{id, int(submapper)}]; ok {
+		return constructor, true
+	}
+	constructor, ok := mapperRegistry.constructors[mapperKey{id, AnySubmapper}]
+	return constructor, ok
+}
+
+//ResetMapper gets the current mapper representing the cartridge.
+func (system *System) ResetMapper() (Mapper, error) {
+	header := system.memory.cartridge.header
+	constructor, ok := lookupMapper(header.MapperNumber, header.SubmapperNumber)
+	if !ok {
+		return nil, ErrUnsupportedMapper{ID: int(header.MapperNumber)}
+	}
+	return constructor(&system.memory), nil
+}
+
+//readPRGRAM reads the PRG-RAM mapped at $6000-$7FFF, mirroring RAM smaller than 8KB.
+func readPRGRAM(prgRAM []byte, addr uint16) byte {
+	if len(prgRAM) == 0 {
+		return 0
+	}
+	return prgRAM[int(addr-0x6000)%len(prgRAM)]
+}
+
+//writePRGRAM writes the PRG-RAM mapped at $6000-$7FFF, mirroring RAM smaller than 8KB.
+func writePRGRAM(prgRAM []byte, addr uint16, data byte) {
+	if len(prgRAM) == 0 {
+		return
+	}
+	prgRAM[int(addr-0x6000)%len(prgRAM)] = data
+}
+
+//TranslateVRamAddress takes a physical address to a VRam one.
+func TranslateVRamAddress(address uint16, mirrorMode int) int {
+	address -= 0x2000
+	bank := MirrorLookup[mirrorMode][address/(0x400)]
+	return (bank * 0x400) + int(address%0x400)
+}
@@ -1,4 +1,4 @@
-package main
+package nes
 
 //Mapper0 represents the snes Mapper0, simple and direct.
 type Mapper0 struct {
@@ -19,6 +19,8 @@ func (mapper *Mapper0) ReadByte(addr uint16) byte {
 		return mapper.memory.cartridge.chr[addr]
 	case addr <= 0x2FFF:
 		return mapper.memory.ppu.vram[TranslateVRamAddress(addr, mapper.memory.cartridge.mirrorMode)]
+	case addr >= 0x6000 && addr <= 0x7FFF:
+		return readPRGRAM(mapper.memory.cartridge.prgRAM, addr)
 	case addr >= 0x8000 && addr <= 0xBFFF:
 		return mapper.memory.cartridge.prg[addr-0x8000]
 	case addr >= 0xC000 && addr <= 0xFFFF:
@@ -39,11 +41,10 @@ func (mapper *Mapper0) Emulate() {
 func (mapper *Mapper0) WriteByte(addr uint16, data byte) {
 	switch {
 	case addr <= 0x1FFF:
-		if int(addr) > len(mapper.memory.cartridge.chr) {
-			print("Oh no")
-		}
 		mapper.memory.cartridge.chr[addr] = data
 	case addr <= 0x2FFF:
 		mapper.memory.ppu.vram[TranslateVRamAddress(addr, mapper.memory.cartridge.mirrorMode)] = data
+	case addr >= 0x6000 && addr <= 0x7FFF:
+		writePRGRAM(mapper.memory.cartridge.prgRAM, addr, data)
 	}
 }
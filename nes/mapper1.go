@@ -1,4 +1,6 @@
-package main
+package nes
+
+import "encoding/gob"
 
 //MapperMMC1 presents the MMC1 Mapper.
 type MapperMMC1 struct {
@@ -14,14 +16,47 @@ type MapperMMC1 struct {
 	registerCHR1    byte
 	registerPRG     byte
 
-	prgRAM [8192]byte
+	prgRAM []byte
 }
 
 func (memory *Memory) resetMapperMMC1() *MapperMMC1 {
 	return &MapperMMC1{
 		memory:          memory,
 		registerControl: 0x0f,
+		prgRAM:          memory.cartridge.prgRAM,
+	}
+}
+
+func (mapper *MapperMMC1) Save(encoder *gob.Encoder) error {
+	return encodeAll(encoder,
+		mapper.shiftRegister,
+		mapper.shiftNumber,
+		mapper.mirrorMode,
+		mapper.registerControl,
+		mapper.registerCHR0,
+		mapper.registerCHR1,
+		mapper.registerPRG,
+	)
+}
+
+func (mapper *MapperMMC1) Load(decoder *gob.Decoder) error {
+	return decodeAll(decoder,
+		&mapper.shiftRegister,
+		&mapper.shiftNumber,
+		&mapper.mirrorMode,
+		&mapper.registerControl,
+		&mapper.registerCHR0,
+		&mapper.registerCHR1,
+		&mapper.registerPRG,
+	)
+}
+
+//BatteryRAM returns the PRG-RAM if the cartridge keeps it powered.
+func (mapper *MapperMMC1) BatteryRAM() []byte {
+	if !mapper.memory.cartridge.header.BatteryBacked {
+		return nil
 	}
+	return mapper.prgRAM
 }
 
 //ReadByte reads a byte according to the MMC1 mapper.
@@ -37,10 +72,11 @@ func (mapper *MapperMMC1) ReadByte(addr uint16) byte {
 		// mirroring
 		return mapper.memory.ppu.vram[TranslateVRamAddress(addr, mapper.mirrorMode)]
 	case addr < 0x6000:
-		panic("Unknown mapper address.")
+		// open bus
+		return 0
 	case addr >= 0x6000 && addr <= 0x7FFF:
 		// internal ram
-		return mapper.prgRAM[addr-0x6000]
+		return readPRGRAM(mapper.prgRAM, addr)
 	case addr <= 0xBFFF:
 		// PRG bank 1
 		switch (mapper.registerControl & 0xC) >> 2 {
@@ -108,7 +144,7 @@ func (mapper *MapperMMC1) WriteByte(addr uint16, data byte) {
 		}
 	} else if addr <= 0x7FFF {
 		if mapper.registerPRG&0x10 == 0 {
-			mapper.prgRAM[addr-0x6000] = data
+			writePRGRAM(mapper.prgRAM, addr, data)
 		}
 	} else {
 		if data&0x80 > 0 {
@@ -0,0 +1,63 @@
+package nes
+
+import "encoding/gob"
+
+//Mapper3 stores the state of the mapper 3 struct.
+type Mapper3 struct {
+	memory   *Memory
+	bank     int
+	numBanks int
+}
+
+func (memory *Memory) resetMapper3() *Mapper3 {
+	numBanks := len(memory.cartridge.chr) / 8192
+	return &Mapper3{
+		memory:   memory,
+		numBanks: numBanks,
+		bank:     numBanks - 1,
+	}
+}
+
+func (mapper *Mapper3) Save(encoder *gob.Encoder) error {
+	return encoder.Encode(mapper.bank)
+}
+
+func (mapper *Mapper3) Load(decoder *gob.Decoder) error {
+	return decoder.Decode(&mapper.bank)
+}
+
+//ReadByte reads a byte according to mapper 3.
+func (mapper *Mapper3) ReadByte(addr uint16) byte {
+	switch {
+	case addr <= 0x1FFF:
+		return mapper.memory.cartridge.chr[uint16(mapper.bank*8192)+addr]
+	case addr <= 0x2FFF:
+		return mapper.memory.ppu.vram[TranslateVRamAddress(addr, mapper.memory.cartridge.mirrorMode)]
+	case addr >= 0x8000 && addr <= 0xBFFF:
+		return mapper.memory.cartridge.prg[addr-0x8000]
+	case addr >= 0xC000 && addr <= 0xFFFF:
+		if len(mapper.memory.cartridge.prg) > 0x4000 {
+			return mapper.memory.cartridge.prg[addr-0x8000]
+		}
+		return mapper.memory.cartridge.prg[addr-0xC000]
+	default:
+		// open bus
+		return 0
+	}
+}
+
+//WriteByte writes a byte according to mapper 3.
+func (mapper *Mapper3) WriteByte(addr uint16, data byte) {
+	switch {
+	case addr <= 0x1FFF:
+		// CHR rom is read only.
+	case addr <= 0x2FFF:
+		mapper.memory.ppu.vram[TranslateVRamAddress(addr, mapper.memory.cartridge.mirrorMode)] = data
+	case addr >= 0x8000:
+		mapper.bank = int(data) % mapper.numBanks
+	}
+}
+
+func (mapper *Mapper3) Emulate() {
+	return
+}
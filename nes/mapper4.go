@@ -1,10 +1,13 @@
-package main
+package nes
+
+import "encoding/gob"
 
 //MapperMMC3 represents mapper id 4.
 type MapperMMC3 struct {
 	memory *Memory
 
 	irqEnabled bool
+	irqPending bool
 	irqLatch   byte
 	irqReload  byte
 
@@ -15,7 +18,7 @@ type MapperMMC3 struct {
 	bankPRGMode        int
 	bankCHRMode        int
 
-	prgRAM [8192]byte
+	prgRAM []byte
 
 	counter byte
 }
@@ -25,21 +28,60 @@ func (memory *Memory) resetMapperMMC3() *MapperMMC3 {
 		memory:     memory,
 		irqEnabled: true,
 		irqReload:  0,
+		prgRAM:     memory.cartridge.prgRAM,
 	}
 }
 
+func (mapper *MapperMMC3) Save(encoder *gob.Encoder) error {
+	return encodeAll(encoder,
+		mapper.irqEnabled,
+		mapper.irqPending,
+		mapper.irqLatch,
+		mapper.irqReload,
+		mapper.mirrorMode,
+		mapper.bankRegisters,
+		mapper.bankSelectRegister,
+		mapper.bankPRGMode,
+		mapper.bankCHRMode,
+		mapper.counter,
+	)
+}
+
+func (mapper *MapperMMC3) Load(decoder *gob.Decoder) error {
+	return decodeAll(decoder,
+		&mapper.irqEnabled,
+		&mapper.irqPending,
+		&mapper.irqLatch,
+		&mapper.irqReload,
+		&mapper.mirrorMode,
+		&mapper.bankRegisters,
+		&mapper.bankSelectRegister,
+		&mapper.bankPRGMode,
+		&mapper.bankCHRMode,
+		&mapper.counter,
+	)
+}
+
+//BatteryRAM returns the PRG-RAM if the cartridge keeps it powered.
+func (mapper *MapperMMC3) BatteryRAM() []byte {
+	if !mapper.memory.cartridge.header.BatteryBacked {
+		return nil
+	}
+	return mapper.prgRAM
+}
+
 //ReadByte acts like mapper mmc 3 reading a byte.
 func (mapper *MapperMMC3) ReadByte(addr uint16) byte {
 	switch {
 	case addr <= 0x1FFF:
 		return mapper.memory.cartridge.chr[mapper.resolvePpuRomAddr(addr)]
 	case addr <= 0x2FFF:
-		return mapper.memory.ppu.vram[TranslateVRamAddress(addr, 1-mapper.mirrorMode)]
+		return mapper.memory.ppu.vram[TranslateVRamAddress(addr, mapper.nametableMirrorMode())]
 	case addr < 0x6000:
 		// ?????
 	case addr <= 0x7FFF:
 		// internal ram
-		return mapper.prgRAM[addr-0x6000]
+		return readPRGRAM(mapper.prgRAM, addr)
 	case addr <= 0xFFFF:
 		return mapper.memory.cartridge.prg[mapper.resolveCPURomAddr(addr)]
 	}
@@ -52,12 +94,12 @@ func (mapper *MapperMMC3) WriteByte(addr uint16, data byte) {
 	case addr <= 0x1FFF:
 		mapper.memory.cartridge.chr[mapper.resolvePpuRomAddr(addr)] = data
 	case addr <= 0x2FFF:
-		mapper.memory.ppu.vram[TranslateVRamAddress(addr, 1-mapper.mirrorMode)] = data
+		mapper.memory.ppu.vram[TranslateVRamAddress(addr, mapper.nametableMirrorMode())] = data
 	case addr < 0x6000:
 		// ?????
 	case addr <= 0x7FFF:
 		// write to prg ram
-		mapper.prgRAM[addr-0x6000] = data
+		writePRGRAM(mapper.prgRAM, addr, data)
 	case addr <= 0x9FFF && (addr&0x1 == 0):
 		// bank select register
 		mapper.bankSelectRegister = int(data & 0x7)
@@ -83,15 +125,23 @@ func (mapper *MapperMMC3) WriteByte(addr uint16, data byte) {
 		// IRQ reload register
 		mapper.irqReload = data
 	case addr <= 0xFFFF && (addr&0x1 == 0):
-		// IRQ disable register
-		// TODO acknowledge pending interrupts (??)
+		// IRQ disable register, which also acknowledges a pending interrupt
 		mapper.irqEnabled = false
+		mapper.irqPending = false
 	case addr <= 0xFFFF && (addr&0x1 == 1):
 		// IRQ enable register
 		mapper.irqEnabled = true
 	}
 }
 
+//nametableMirrorMode returns the mirroring register unless the board has four-screen VRAM.
+func (mapper *MapperMMC3) nametableMirrorMode() int {
+	if mapper.memory.cartridge.mirrorMode == MirrorFour {
+		return MirrorFour
+	}
+	return 1 - mapper.mirrorMode
+}
+
 func (mapper *MapperMMC3) resolvePpuRomAddr(addr uint16) int {
 	bankAddr := addr & 0x3FF
 	bankIndex := int(addr&0x1C00) >> 10
@@ -123,28 +173,29 @@ func (mapper *MapperMMC3) resolveCPURomAddr(addr uint16) int {
 		case addr <= 0xBFFF:
 			return (8192 * mapper.bankRegisters[7]) + int(addr-0xA000)
 		case addr <= 0xDFFF:
-			return (8192*-2 + len(system.memory.cartridge.prg)) + int(addr-0xC000)
-		case addr <= 0xFFFF:
-			return (8192*-1 + len(system.memory.cartridge.prg)) + int(addr-0xE000)
+			return (8192*-2 + len(mapper.memory.cartridge.prg)) + int(addr-0xC000)
 		default:
-			panic("PRG0 Out of bounds!")
+			return (8192*-1 + len(mapper.memory.cartridge.prg)) + int(addr-0xE000)
 		}
 	} else {
 		switch {
 		case addr <= 0x9FFF:
-			return (8192*-2 + len(system.memory.cartridge.prg)) + int(addr-0x8000)
+			return (8192*-2 + len(mapper.memory.cartridge.prg)) + int(addr-0x8000)
 		case addr <= 0xBFFF:
 			return (8192 * mapper.bankRegisters[7]) + int(addr-0xA000)
 		case addr <= 0xDFFF:
 			return (8192 * mapper.bankRegisters[6]) + int(addr-0xC000)
-		case addr <= 0xFFFF:
-			return (8192*-1 + len(system.memory.cartridge.prg)) + int(addr-0xE000)
 		default:
-			panic("PRG1 Out of bounds!")
+			return (8192*-1 + len(mapper.memory.cartridge.prg)) + int(addr-0xE000)
 		}
 	}
 }
 
+//IRQPending reports whether the scanline counter is asserting the IRQ line.
+func (mapper *MapperMMC3) IRQPending() bool {
+	return mapper.irqPending
+}
+
 func (mapper *MapperMMC3) Emulate() {
 	if mapper.memory.ppu.scanlineCount != 280 { // TODO: this *should* be 260
 		return
@@ -164,7 +215,7 @@ func (mapper *MapperMMC3) handleScanLine() {
 	} else {
 		mapper.irqReload--
 		if mapper.irqReload == 0 && mapper.irqEnabled {
-			mapper.memory.cpu.triggerInterruptIRQ()
+			mapper.irqPending = true
 		}
 	}
 }
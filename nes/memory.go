@@ -1,4 +1,6 @@
-package main
+package nes
+
+import "encoding/gob"
 
 // TODO: PPURegisters are mirrored in here and in the PPU class; there can only be one.
 
@@ -23,19 +25,69 @@ type Memory struct {
 	cartridge *Cartridge
 	//The mapper we are using under the hood for ram.
 	mapper Mapper
-	//Controllers
-	controller *[2]Controller
+	//Optional mapper capabilities, detected when the mapper is set.
+	stateSaver  StateSaver
+	irqSource   IRQSource
+	ppuObserver PPUBusObserver
+	audio       AudioExpansion
+	//Input devices in the controller and expansion ports.
+	input *inputPorts
 	//PPU for ppu memory access.
 	ppu *PPU
 	//CPU for cpu memory access.
 	cpu *CPU
+	//APU for the sound and frame counter registers.
+	apu *APU
 }
 
 func (system *System) resetMemory() {
 	system.memory = Memory{}
 	system.memory.ppu = &system.ppu
 	system.memory.cpu = &system.cpu
-	system.memory.controller = &system.controller
+	system.memory.apu = &system.apu
+	system.memory.input = &system.input
+}
+
+func (memory *Memory) Save(encoder *gob.Encoder) error {
+	return encodeAll(encoder,
+		memory.RAM,
+		memory.PPURegisters,
+		memory.APURegisters,
+		memory.DisabledRegsiters,
+	)
+}
+
+func (memory *Memory) Load(decoder *gob.Decoder) error {
+	return decodeAll(decoder,
+		&memory.RAM,
+		&memory.PPURegisters,
+		&memory.APURegisters,
+		&memory.DisabledRegsiters,
+	)
+}
+
+//setMapper installs the mapper and detects which optional capabilities it has.
+func (memory *Memory) setMapper(mapper Mapper) {
+	memory.mapper = mapper
+	memory.stateSaver, _ = mapper.(StateSaver)
+	memory.irqSource, _ = mapper.(IRQSource)
+	memory.ppuObserver, _ = mapper.(PPUBusObserver)
+	memory.audio, _ = mapper.(AudioExpansion)
+}
+
+//Cartridge returns the cartridge the mapper is built for.
+func (memory *Memory) Cartridge() *Cartridge {
+	return memory.cartridge
+}
+
+//ReadNametable reads the console's nametable RAM through the given mirroring.
+func (memory *Memory) ReadNametable(address uint16, mirrorMode int) byte {
+	return memory.ppu.vram[TranslateVRamAddress(address, mirrorMode)]
+}
+
+//WriteNametable writes the console's nametable RAM through the given mirroring.
+func (memory *Memory) WriteNametable(address uint16, mirrorMode int, value byte) {
+	memory.ppu.vram[TranslateVRamAddress(address, mirrorMode)] = value
 }
 
 //WriteByte Writes a byte to the given address.
@@ -49,12 +101,12 @@ func (memory *Memory) WriteByte(address uint16, value byte) {
 		// OAMDMA
 		memory.ppu.WriteRegister(0x4014, value)
 	case address == 0x4016:
-		memory.controller[0].Write(value)
-		memory.controller[1].Write(value)
+		memory.input.write(value)
+	case address <= 0x4017:
+		memory.apu.writeRegister(address, value)
 	case address >= 0x4020:
 		memory.mapper.WriteByte(address, value)
 	}
-	// TODO do the APU and I/O
 }
 
 //ReadByte Reads a byte from the ram.
@@ -66,11 +118,13 @@ func (memory *Memory) ReadByte(address uint16) byte {
 	case address <= 0x3FFF:
 		return memory.ppu.ReadRegister(int(address & 0x7))
 	case address == 0x4016:
-		return memory.controller[0].Read()
+		return memory.input.read(0)
 	case address == 0x4017:
-		return memory.controller[1].Read()
+		return memory.input.read(1)
+	case address == 0x4015:
+		return memory.apu.readRegister(address)
 	case address <= 0x4017:
-		// APU & IO Registers
+		// The other APU registers are write only.
 		return 0
 	case address <= 0x401F:
 		// CPU test mode
@@ -0,0 +1,111 @@
+package nes
+
+//Mixer sets how loud each channel is in the APU's mixed output. Muted channels, and channels
+//left out while others are soloed, are mixed as if they were silent, so the nonlinear mixing
+//of the remaining channels is the same as on the console.
+//Per-channel captures set with SetChannelSink are taken before the mixer.
+type Mixer struct {
+	volume [channelCount]float32
+	muted  [channelCount]bool
+	solo   [channelCount]bool
+	//unity is set while every channel plays at full volume, so the lookup tables can be used.
+	unity bool
+}
+
+//Mixer returns the system's mixer. Its settings survive resets and loading states.
+func (system *System) Mixer() *Mixer {
+	return &system.mixer
+}
+
+//Reset puts every channel back to full volume, unmuted and not soloed.
+func (mixer *Mixer) Reset() {
+	*mixer = Mixer{}
+	for i := range mixer.volume {
+		mixer.volume[i] = 1
+	}
+	mixer.unity = true
+}
+
+//SetVolume scales a channel, 1 being the console's level. Volumes above 1 are allowed.
+func (mixer *Mixer) SetVolume(channel int, volume float32) {
+	if volume < 0 {
+		volume = 0
+	}
+	mixer.volume[channel] = volume
+	mixer.update()
+}
+
+//Volume returns a channel's volume.
+func (mixer *Mixer) Volume(channel int) float32 {
+	return mixer.volume[channel]
+}
+
+//SetMuted silences or restores a channel.
+func (mixer *Mixer) SetMuted(channel int, muted bool) {
+	mixer.muted[channel] = muted
+	mixer.update()
+}
+
+//Muted reports whether a channel is muted.
+func (mixer *Mixer) Muted(channel int) bool {
+	return mixer.muted[channel]
+}
+
+//SetSolo adds a channel to or removes it from the soloed channels. While any channel
+//is soloed only the soloed channels are heard.
+func (mixer *Mixer) SetSolo(channel int, solo bool) {
+	mixer.solo[channel] = solo
+	mixer.update()
+}
+
+//Solo reports whether a channel is soloed.
+func (mixer *Mixer) Solo(channel int) bool {
+	return mixer.solo[channel]
+}
+
+//Gain returns the factor a channel's level is currently multiplied by.
+func (mixer *Mixer) Gain(channel int) float32 {
+	if mixer.muted[channel] {
+		return 0
+	}
+	if mixer.soloing() && !mixer.solo[channel] {
+		return 0
+	}
+	return mixer.volume[channel]
+}
+
+func (mixer *Mixer) soloing() bool {
+	for _, solo := range mixer.solo {
+		if solo {
+			return true
+		}
+	}
+	return false
+}
+
+func (mixer *Mixer) update() {
+	mixer.unity = true
+	for channel := range mixer.volume {
+		if mixer.Gain(channel) != 1 {
+			mixer.unity = false
+		}
+	}
+}
+
+//mix combines the channels' levels through the APU's nonlinear DACs,
+//using the same approximation as pulseTable and tndTable.
+func (mixer *Mixer) mix(p1, p2, t, n, d byte, expansion float32) float32 {
+	if mixer.unity {
+		return pulseTable[p1+p2] + tndTable[3*t+2*n+d] + expansion
+	}
+	output := expansion * mixer.Gain(ChannelExpansion)
+	pulse := float32(p1)*mixer.Gain(ChannelPulse1) + float32(p2)*mixer.Gain(ChannelPulse2)
+	if pulse > 0 {
+		output += 95.52 / (8128.0/pulse + 100)
+	}
+	tnd := 3*float32(t)*mixer.Gain(ChannelTriangle) + 2*float32(n)*mixer.Gain(ChannelNoise) + float32(d)*mixer.Gain(ChannelDMC)
+	if tnd > 0 {
+		output += 163.67 / (24329.0/tnd + 100)
+	}
+	return output
+}
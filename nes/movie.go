@@ -0,0 +1,474 @@
+package nes
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/binary"
+	"errors"
+	"io"
+)
+
+const (
+	movieMagic   = "NGM\x1A"
+	movieVersion = 4
+)
+
+//Bits of the command byte that starts every frame in the binary format.
+const (
+	movieCommandReset = 1 << iota
+	movieCommandPowerCycle
+	//movieCommandTurboRate means a byte with each port's turbo rate follows the frame.
+	movieCommandTurboRate
+)
+
+var (
+	//ErrBadMovie is returned when a movie file is corrupt or in a format we can't read.
+	ErrBadMovie = errors.New("nes: not a valid movie")
+	//ErrMovieROMMismatch is returned when a movie was recorded with a different ROM.
+	ErrMovieROMMismatch = errors.New("nes: movie was recorded with a different ROM")
+	//ErrMovieDevices is returned when a movie was recorded with input devices that aren't plugged in.
+	ErrMovieDevices = errors.New("nes: movie was recorded with different input devices")
+)
+
+//Bits of the flags byte in the binary format's header.
+const (
+	movieFlagState = 1 << iota
+	//movieFlagMultitap is the low of two bits holding the multitap.
+	movieFlagMultitap
+	_
+	movieFlagDevices
+)
+
+//MovieFrame is the input for a single frame.
+type MovieFrame struct {
+	//Buttons holds one bit per button for each player, bit n being the ButtonX constant n.
+	//Players 3 and 4 are only recorded in movies made with a multitap.
+	Buttons [4]byte
+	//Turbo holds the autofire buttons held by each player, packed like Buttons.
+	Turbo [4]byte
+	//TurboRate is the autofire rate of each player in frames per press, 0 to leave it as it is.
+	//Recordings only fill it in on frames with autofire held.
+	TurboRate [4]int
+	//Devices holds the input of each port's RecordedDevice, in the ports Movie.Devices names.
+	Devices [ExpansionPort + 1][MovieInputSize]byte
+	//Reset presses the reset button before the frame is emulated.
+	Reset bool
+	//PowerCycle switches the system off and on before the frame is emulated. Battery backed RAM is kept.
+	PowerCycle bool
+}
+
+//Movie is a recording of the input for every frame, starting at power on or from a save state.
+//Movies that start at power on start with blank cartridge RAM, even if the game has a battery,
+//so they replay the same whatever save file is loaded.
+type Movie struct {
+	//ROMHash identifies the ROM the movie was made with. All zero when unknown, as with FM2 imports.
+	ROMHash [sha1.Size]byte
+	//State is the save state the movie starts from, or nil to start at power on.
+	State []byte
+	//Multitap is the adapter players 3 and 4 were connected through, set up again for playback.
+	Multitap int
+	//Devices names the RecordedDevice in each port, "" for ports whose device isn't recorded.
+	Devices [ExpansionPort + 1]string
+	Frames  []MovieFrame
+}
+
+//players returns the number of controllers the movie records.
+func (movie *Movie) players() int {
+	if movie.Multitap != MultitapNone {
+		return 4
+	}
+	return 2
+}
+
+//packButtons packs a controller's buttons into a MovieFrame byte.
+func packButtons(buttons [8]bool) byte {
+	var packed byte
+	for i, pressed := range buttons {
+		if pressed {
+			packed |= 1 << uint(i)
+		}
+	}
+	return packed
+}
+
+//unpackButtons expands a MovieFrame byte back into a controller's buttons.
+func unpackButtons(packed byte) [8]bool {
+	var buttons [8]bool
+	for i := range buttons {
+		buttons[i] = packed&(1<<uint(i)) != 0
+	}
+	return buttons
+}
+
+//pressedButtons returns the buttons the game saw on each frame of a movie that starts at power on,
+//with autofire worked out the same way the emulator does it.
+func pressedButtons(movie *Movie) [][4]byte {
+	var controllers [4]Controller
+	pressed := make([][4]byte, len(movie.Frames))
+	for i, frame := range movie.Frames {
+		for port := 0; port < movie.players(); port++ {
+			controller := &controllers[port]
+			if frame.Reset || frame.PowerCycle {
+				controller.resetController()
+			}
+			controller.buttons = unpackButtons(frame.Buttons[port])
+			controller.turbo = unpackButtons(frame.Turbo[port])
+			if frame.TurboRate[port] != 0 {
+				controller.turboRate = frame.TurboRate[port]
+			}
+			var buttons [8]bool
+			for button := range buttons {
+				buttons[button] = controller.pressed(button)
+			}
+			pressed[i][port] = packButtons(buttons)
+			controller.stepTurbo()
+		}
+	}
+	return pressed
+}
+
+//MovieRecorder appends the controller state of every emulated frame to a Movie.
+type MovieRecorder struct {
+	system     *System
+	movie      *Movie
+	reset      bool
+	powerCycle bool
+}
+
+//RecordMovie starts recording system, either from power on or from its current state.
+func RecordMovie(system *System, fromPowerOn bool) (*MovieRecorder, error) {
+	if system.cartridge == nil {
+		return nil, ErrNoCartridge
+	}
+	movie := &Movie{ROMHash: system.cartridge.hash, Multitap: system.multitap}
+	for port, device := range system.input.devices {
+		if recorded, ok := device.(RecordedDevice); ok {
+			movie.Devices[port] = recorded.MovieName()
+		}
+	}
+	if fromPowerOn {
+		if err := system.powerCycle(true); err != nil {
+			return nil, err
+		}
+	} else {
+		var state bytes.Buffer
+		if err := system.SaveState(&state); err != nil {
+			return nil, err
+		}
+		movie.State = state.Bytes()
+	}
+	return &MovieRecorder{system: system, movie: movie}, nil
+}
+
+//Reset presses the reset button at the start of the next recorded frame.
+func (recorder *MovieRecorder) Reset() {
+	recorder.reset = true
+}
+
+//PowerCycle switches the system off and on at the start of the next recorded frame.
+func (recorder *MovieRecorder) PowerCycle() {
+	recorder.powerCycle = true
+}
+
+//StepFrame records the current controller state and emulates one frame.
+func (recorder *MovieRecorder) StepFrame() (int, error) {
+	frame := MovieFrame{Reset: recorder.reset, PowerCycle: recorder.powerCycle}
+	recorder.reset = false
+	recorder.powerCycle = false
+	if err := recorder.system.applyMovieCommands(frame); err != nil {
+		return 0, err
+	}
+	for i := 0; i < recorder.movie.players(); i++ {
+		controller := &recorder.system.controller[i]
+		frame.Buttons[i] = packButtons(controller.buttons)
+		frame.Turbo[i] = packButtons(controller.turbo)
+		if frame.Turbo[i] != 0 {
+			frame.TurboRate[i] = controller.rate()
+		}
+	}
+	for port, name := range recorder.movie.Devices {
+		if recorded, ok := recorder.system.input.devices[port].(RecordedDevice); ok && name != "" && recorded.MovieName() == name {
+			recorded.MovieInput(&frame.Devices[port])
+		}
+	}
+	recorder.movie.Frames = append(recorder.movie.Frames, frame)
+	return recorder.system.EmulateFrame(), nil
+}
+
+//applyMovieCommands power cycles or resets the system as a frame asks.
+func (system *System) applyMovieCommands(frame MovieFrame) error {
+	if frame.PowerCycle {
+		return system.PowerCycle()
+	}
+	if frame.Reset {
+		return system.ResetSystem()
+	}
+	return nil
+}
+
+//Movie returns the recording so far.
+func (recorder *MovieRecorder) Movie() *Movie {
+	return recorder.movie
+}
+
+//MoviePlayer feeds a Movie's input into a System frame by frame.
+type MoviePlayer struct {
+	system *System
+	movie  *Movie
+	frame  int
+}
+
+//PlayMovie puts system at the movie's starting point, ready to replay it, with the movie's multitap connected.
+//The devices the movie records have to be plugged into the same ports already.
+func PlayMovie(system *System, movie *Movie) (*MoviePlayer, error) {
+	if system.cartridge == nil {
+		return nil, ErrNoCartridge
+	}
+	if movie.ROMHash != ([sha1.Size]byte{}) && movie.ROMHash != system.cartridge.hash {
+		return nil, ErrMovieROMMismatch
+	}
+	if movie.State != nil {
+		if err := system.LoadState(bytes.NewReader(movie.State)); err != nil {
+			return nil, err
+		}
+	} else if err := system.powerCycle(true); err != nil {
+		return nil, err
+	}
+	if system.multitap != movie.Multitap {
+		system.SetMultitap(movie.Multitap)
+	}
+	for port, name := range movie.Devices {
+		if recorded, ok := system.input.devices[port].(RecordedDevice); name != "" && (!ok || recorded.MovieName() != name) {
+			return nil, ErrMovieDevices
+		}
+	}
+	return &MoviePlayer{system: system, movie: movie}, nil
+}
+
+//StepFrame applies the next frame of input and emulates it. It returns false once the movie has ended.
+func (player *MoviePlayer) StepFrame() (bool, error) {
+	if player.Done() {
+		return false, nil
+	}
+	frame := player.movie.Frames[player.frame]
+	player.frame++
+	if err := player.system.applyMovieCommands(frame); err != nil {
+		return false, err
+	}
+	for i, buttons := range frame.Buttons {
+		player.system.SetButtons(i, unpackButtons(buttons))
+		player.system.SetTurbo(i, unpackButtons(frame.Turbo[i]))
+		if frame.TurboRate[i] != 0 {
+			player.system.SetTurboRate(i, frame.TurboRate[i])
+		}
+	}
+	for port, name := range player.movie.Devices {
+		if recorded, ok := player.system.input.devices[port].(RecordedDevice); ok && name != "" {
+			recorded.SetMovieInput(&frame.Devices[port])
+		}
+	}
+	player.system.EmulateFrame()
+	return true, nil
+}
+
+//Run plays until the movie ends or limit frames have been emulated (0 for no limit).
+//It returns the number of frames emulated and the first error.
+func (player *MoviePlayer) Run(limit int) (int, error) {
+	frames := 0
+	for limit <= 0 || frames < limit {
+		more, err := player.StepFrame()
+		if err != nil || !more {
+			return frames, err
+		}
+		frames++
+	}
+	return frames, nil
+}
+
+//Frame returns the number of frames played so far.
+func (player *MoviePlayer) Frame() int {
+	return player.frame
+}
+
+//Done reports whether every frame of the movie has been played.
+func (player *MoviePlayer) Done() bool {
+	return player.frame >= len(player.movie.Frames)
+}
+
+//WriteMovie writes movie in NesGo's compact binary format.
+func WriteMovie(w io.Writer, movie *Movie) error {
+	var header bytes.Buffer
+	header.WriteString(movieMagic)
+	header.WriteByte(movieVersion)
+	flags := byte(movie.Multitap) * movieFlagMultitap
+	if movie.State != nil {
+		flags |= movieFlagState
+	}
+	if movie.Devices != [ExpansionPort + 1]string{} {
+		flags |= movieFlagDevices
+	}
+	header.WriteByte(flags)
+	header.Write(movie.ROMHash[:])
+	binary.Write(&header, binary.LittleEndian, uint32(len(movie.Frames)))
+	if movie.State != nil {
+		binary.Write(&header, binary.LittleEndian, uint32(len(movie.State)))
+		header.Write(movie.State)
+	}
+	if flags&movieFlagDevices != 0 {
+		for _, name := range movie.Devices {
+			header.WriteByte(byte(len(name)))
+			header.WriteString(name)
+		}
+	}
+	if _, err := w.Write(header.Bytes()); err != nil {
+		return err
+	}
+
+	// Each frame is a command byte, the buttons and turbo buttons of every player,
+	// their turbo rates if the command says so, then the input of every recorded device
+	// as a length and that many bytes.
+	players := movie.players()
+	frames := make([]byte, 0, len(movie.Frames)*(1+players*2))
+	for _, frame := range movie.Frames {
+		command := byte(0)
+		if frame.Reset {
+			command |= movieCommandReset
+		}
+		if frame.PowerCycle {
+			command |= movieCommandPowerCycle
+		}
+		if frame.TurboRate != [4]int{} {
+			command |= movieCommandTurboRate
+		}
+		frames = append(frames, command)
+		frames = append(frames, frame.Buttons[:players]...)
+		frames = append(frames, frame.Turbo[:players]...)
+		if command&movieCommandTurboRate != 0 {
+			for _, rate := range frame.TurboRate[:players] {
+				frames = append(frames, byte(rate))
+			}
+		}
+		for port, name := range movie.Devices {
+			if name == "" {
+				continue
+			}
+			input := frame.Devices[port][:]
+			for len(input) > 0 && input[len(input)-1] == 0 {
+				input = input[:len(input)-1]
+			}
+			frames = append(frames, byte(len(input)))
+			frames = append(frames, input...)
+		}
+	}
+	_, err := w.Write(frames)
+	return err
+}
+
+//ReadMovie reads a movie written by WriteMovie, including ones from before turbo was recorded.
+func ReadMovie(r io.Reader) (*Movie, error) {
+	var header [len(movieMagic) + 2 + sha1.Size + 4]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		return nil, ErrBadMovie
+	}
+	version := header[len(movieMagic)]
+	if string(header[:len(movieMagic)]) != movieMagic || version < 1 || version > movieVersion {
+		return nil, ErrBadMovie
+	}
+	flags := header[len(movieMagic)+1]
+	movie := &Movie{Multitap: int(flags/movieFlagMultitap) & 3}
+	if movie.Multitap > MultitapFamicom {
+		return nil, ErrBadMovie
+	}
+	copy(movie.ROMHash[:], header[len(movieMagic)+2:])
+	frameCount := binary.LittleEndian.Uint32(header[len(header)-4:])
+
+	if flags&movieFlagState != 0 {
+		var stateLength uint32
+		if err := binary.Read(r, binary.LittleEndian, &stateLength); err != nil {
+			return nil, ErrBadMovie
+		}
+		// The length isn't trusted: read what is there rather than allocating it up front.
+		state, err := io.ReadAll(io.LimitReader(r, int64(stateLength)))
+		if err != nil {
+			return nil, err
+		}
+		if len(state) != int(stateLength) {
+			return nil, ErrBadMovie
+		}
+		movie.State = state
+	}
+
+	if flags&movieFlagDevices != 0 {
+		for port := range movie.Devices {
+			var name [256]byte
+			if _, err := io.ReadFull(r, name[:1]); err != nil {
+				return nil, ErrBadMovie
+			}
+			length := int(name[0])
+			if _, err := io.ReadFull(r, name[:length]); err != nil {
+				return nil, ErrBadMovie
+			}
+			movie.Devices[port] = string(name[:length])
+		}
+	}
+
+	frames, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	if uint64(frameCount)*3 > uint64(len(frames)) {
+		return nil, ErrBadMovie
+	}
+	movie.Frames = make([]MovieFrame, frameCount)
+	players := movie.players()
+	for i := range movie.Frames {
+		if version == 1 {
+			// Version 1 frames are a command and the buttons of each port.
+			if len(frames) < 3 {
+				return nil, ErrBadMovie
+			}
+			movie.Frames[i] = MovieFrame{
+				Reset:      frames[0]&movieCommandReset != 0,
+				PowerCycle: frames[0]&movieCommandPowerCycle != 0,
+				Buttons:    [4]byte{frames[1], frames[2]},
+			}
+			frames = frames[3:]
+			continue
+		}
+		if len(frames) < 1+players*2 {
+			return nil, ErrBadMovie
+		}
+		command := frames[0]
+		frame := MovieFrame{
+			Reset:      command&movieCommandReset != 0,
+			PowerCycle: command&movieCommandPowerCycle != 0,
+		}
+		copy(frame.Buttons[:], frames[1:1+players])
+		copy(frame.Turbo[:], frames[1+players:1+players*2])
+		frames = frames[1+players*2:]
+		if command&movieCommandTurboRate != 0 {
+			if len(frames) < players {
+				return nil, ErrBadMovie
+			}
+			for player, rate := range frames[:players] {
+				frame.TurboRate[player] = int(rate)
+			}
+			frames = frames[players:]
+		}
+		for port, name := range movie.Devices {
+			if name == "" {
+				continue
+			}
+			if len(frames) < 1 || int(frames[0]) > MovieInputSize || len(frames) < 1+int(frames[0]) {
+				return nil, ErrBadMovie
+			}
+			copy(frame.Devices[port][:], frames[1:1+int(frames[0])])
+			frames = frames[1+int(frames[0]):]
+		}
+		movie.Frames[i] = frame
+	}
+	if len(frames) != 0 {
+		return nil, ErrBadMovie
+	}
+	return movie, nil
+}
@@ -0,0 +1,113 @@
+package nes
+
+import "encoding/gob"
+
+//Multitaps that connect four controllers.
+const (
+	//MultitapNone has controllers 1 and 2 in the ports and nothing for players 3 and 4.
+	MultitapNone = iota
+	//MultitapFourScore is the NES Four Score in both controller ports.
+	MultitapFourScore
+	//MultitapFamicom is a Famicom four player adapter in the expansion port, which reads
+	//players 3 and 4 on D1 of $4016 and $4017 next to the built in controllers on D0.
+	MultitapFamicom
+)
+
+//fourScoreSignatures are shifted out of $4016 and $4017 after the two controllers on each,
+//and tell games a Four Score is plugged in.
+var fourScoreSignatures = [2]byte{0x10, 0x20}
+
+//fourScore reads players 1 and 3 through $4016 and players 2 and 4 through $4017, eight
+//buttons each, then eight bits of signature. It's plugged into both controller ports.
+type fourScore struct {
+	controllers [4]*Controller
+	index       [2]int
+	strobe      byte
+}
+
+func (adapter *fourScore) Read(port int) byte {
+	index := adapter.index[port]
+	var data byte
+	switch {
+	case index < 8:
+		if adapter.controllers[port].pressed(index) {
+			data = 1
+		}
+	case index < 16:
+		if adapter.controllers[port+2].pressed(index - 8) {
+			data = 1
+		}
+	case index < 24:
+		data = fourScoreSignatures[port] >> uint(index-16) & 1
+	default:
+		// The Four Score returns 1 once its report has been read.
+		data = 1
+	}
+	if adapter.strobe&1 == 0 && index < 24 {
+		adapter.index[port]++
+	}
+	return data
+}
+
+func (adapter *fourScore) Write(out byte) {
+	adapter.strobe = out
+	if out&1 == 1 {
+		adapter.index = [2]int{}
+	}
+}
+
+func (adapter *fourScore) Save(encoder *gob.Encoder) error {
+	return encodeAll(encoder, adapter.index, adapter.strobe)
+}
+
+func (adapter *fourScore) Load(decoder *gob.Decoder) error {
+	return decodeAll(decoder, &adapter.index, &adapter.strobe)
+}
+
+//famicomMultitap puts players 3 and 4 on D1 of $4016 and $4017.
+type famicomMultitap struct {
+	controllers [2]*Controller
+}
+
+func (adapter *famicomMultitap) Read(port int) byte {
+	return adapter.controllers[port].Read(port) << 1
+}
+
+func (adapter *famicomMultitap) Write(out byte) {
+	for _, controller := range adapter.controllers {
+		controller.Write(out)
+	}
+}
+
+//SetMultitap connects players 3 and 4 through the given Multitap adapter, or disconnects them
+//with MultitapNone. This replaces the devices in the ports the adapter uses.
+func (system *System) SetMultitap(multitap int) {
+	system.multitap = multitap
+	switch multitap {
+	case MultitapFourScore:
+		adapter := &fourScore{}
+		for i := range adapter.controllers {
+			adapter.controllers[i] = &system.controller[i]
+		}
+		system.SetInputDevice(0, adapter)
+		system.SetInputDevice(1, adapter)
+	case MultitapFamicom:
+		system.SetInputDevice(0, &system.controller[0])
+		system.SetInputDevice(1, &system.controller[1])
+		system.SetInputDevice(ExpansionPort, &famicomMultitap{
+			controllers: [2]*Controller{&system.controller[2], &system.controller[3]},
+		})
+	default:
+		system.multitap = MultitapNone
+		system.SetInputDevice(0, &system.controller[0])
+		system.SetInputDevice(1, &system.controller[1])
+		if _, ok := system.input.devices[ExpansionPort].(*famicomMultitap); ok {
+			system.SetInputDevice(ExpansionPort, nil)
+		}
+	}
+}
+
+//Multitap returns the adapter players 3 and 4 are connected through.
+func (system *System) Multitap() int {
+	return system.multitap
+}
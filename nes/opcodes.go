@@ -0,0 +1,782 @@
+package nes
+
+//addressMode is how an instruction finds the address it operates on.
+type addressMode byte
+
+const (
+	modeImplied addressMode = iota
+	modeAccumulator
+	modeImmediate
+	modeZeroPage
+	modeZeroPageX
+	modeZeroPageY
+	modeRelative
+	modeAbsolute
+	modeAbsoluteX
+	modeAbsoluteY
+	modeIndirect
+	modeIndirectX
+	modeIndirectY
+)
+
+//size returns the length in bytes of an instruction using the mode, opcode included.
+func (mode addressMode) size() int {
+	switch mode {
+	case modeImplied, modeAccumulator:
+		return 1
+	case modeAbsolute, modeAbsoluteX, modeAbsoluteY, modeIndirect:
+		return 3
+	}
+	return 2
+}
+
+//opcode describes one of the 256 opcodes.
+type opcode struct {
+	mnemonic string
+	mode     addressMode
+	//cycles is how long the instruction takes. Taken branches take one more, two if they cross a page.
+	cycles int
+	//pageCycles is added when indexing crosses a page.
+	pageCycles int
+	//execute runs the instruction on the address worked out from mode, with pc already past it.
+	execute func(cpu *CPU, addr uint16)
+	//unofficial is set for the opcodes the 6502 was never documented to have.
+	unofficial bool
+}
+
+//opcodes is the 6502 instruction set, used both to run and to disassemble code.
+var opcodes = [256]opcode{
+	0x00: {"BRK", modeImplied, 7, 0, (*CPU).brk, false},
+	0x01: {"ORA", modeIndirectX, 6, 0, (*CPU).ora, false},
+	0x02: {"KIL", modeImplied, 2, 0, (*CPU).kil, true},
+	0x03: {"SLO", modeIndirectX, 8, 0, (*CPU).slo, true},
+	0x04: {"NOP", modeZeroPage, 3, 0, (*CPU).nop, true},
+	0x05: {"ORA", modeZeroPage, 3, 0, (*CPU).ora, false},
+	0x06: {"ASL", modeZeroPage, 5, 0, (*CPU).asl, false},
+	0x07: {"SLO", modeZeroPage, 5, 0, (*CPU).slo, true},
+	0x08: {"PHP", modeImplied, 3, 0, (*CPU).php, false},
+	0x09: {"ORA", modeImmediate, 2, 0, (*CPU).ora, false},
+	0x0A: {"ASL", modeAccumulator, 2, 0, (*CPU).aslAccumulator, false},
+	0x0B: {"ANC", modeImmediate, 2, 0, (*CPU).anc, true},
+	0x0C: {"NOP", modeAbsolute, 4, 0, (*CPU).nop, true},
+	0x0D: {"ORA", modeAbsolute, 4, 0, (*CPU).ora, false},
+	0x0E: {"ASL", modeAbsolute, 6, 0, (*CPU).asl, false},
+	0x0F: {"SLO", modeAbsolute, 6, 0, (*CPU).slo, true},
+	0x10: {"BPL", modeRelative, 2, 0, (*CPU).bpl, false},
+	0x11: {"ORA", modeIndirectY, 5, 1, (*CPU).ora, false},
+	0x12: {"KIL", modeImplied, 2, 0, (*CPU).kil, true},
+	0x13: {"SLO", modeIndirectY, 8, 0, (*CPU).slo, true},
+	0x14: {"NOP", modeZeroPageX, 4, 0, (*CPU).nop, true},
+	0x15: {"ORA", modeZeroPageX, 4, 0, (*CPU).ora, false},
+	0x16: {"ASL", modeZeroPageX, 6, 0, (*CPU).asl, false},
+	0x17: {"SLO", modeZeroPageX, 6, 0, (*CPU).slo, true},
+	0x18: {"CLC", modeImplied, 2, 0, (*CPU).clc, false},
+	0x19: {"ORA", modeAbsoluteY, 4, 1, (*CPU).ora, false},
+	0x1A: {"NOP", modeImplied, 2, 0, (*CPU).nop, true},
+	0x1B: {"SLO", modeAbsoluteY, 7, 0, (*CPU).slo, true},
+	0x1C: {"NOP", modeAbsoluteX, 4, 1, (*CPU).nop, true},
+	0x1D: {"ORA", modeAbsoluteX, 4, 1, (*CPU).ora, false},
+	0x1E: {"ASL", modeAbsoluteX, 7, 0, (*CPU).asl, false},
+	0x1F: {"SLO", modeAbsoluteX, 7, 0, (*CPU).slo, true},
+	0x20: {"JSR", modeAbsolute, 6, 0, (*CPU).jsr, false},
+	0x21: {"AND", modeIndirectX, 6, 0, (*CPU).and, false},
+	0x22: {"KIL", modeImplied, 2, 0, (*CPU).kil, true},
+	0x23: {"RLA", modeIndirectX, 8, 0, (*CPU).rla, true},
+	0x24: {"BIT", modeZeroPage, 3, 0, (*CPU).bit, false},
+	0x25: {"AND", modeZeroPage, 3, 0, (*CPU).and, false},
+	0x26: {"ROL", modeZeroPage, 5, 0, (*CPU).rol, false},
+	0x27: {"RLA", modeZeroPage, 5, 0, (*CPU).rla, true},
+	0x28: {"PLP", modeImplied, 4, 0, (*CPU).plp, false},
+	0x29: {"AND", modeImmediate, 2, 0, (*CPU).and, false},
+	0x2A: {"ROL", modeAccumulator, 2, 0, (*CPU).rolAccumulator, false},
+	0x2B: {"ANC", modeImmediate, 2, 0, (*CPU).anc, true},
+	0x2C: {"BIT", modeAbsolute, 4, 0, (*CPU).bit, false},
+	0x2D: {"AND", modeAbsolute, 4, 0, (*CPU).and, false},
+	0x2E: {"ROL", modeAbsolute, 6, 0, (*CPU).rol, false},
+	0x2F: {"RLA", modeAbsolute, 6, 0, (*CPU).rla, true},
+	0x30: {"BMI", modeRelative, 2, 0, (*CPU).bmi, false},
+	0x31: {"AND", modeIndirectY, 5, 1, (*CPU).and, false},
+	0x32: {"KIL", modeImplied, 2, 0, (*CPU).kil, true},
+	0x33: {"RLA", modeIndirectY, 8, 0, (*CPU).rla, true},
+	0x34: {"NOP", modeZeroPageX, 4, 0, (*CPU).nop, true},
+	0x35: {"AND", modeZeroPageX, 4, 0, (*CPU).and, false},
+	0x36: {"ROL", modeZeroPageX, 6, 0, (*CPU).rol, false},
+	0x37: {"RLA", modeZeroPageX, 6, 0, (*CPU).rla, true},
+	0x38: {"SEC", modeImplied, 2, 0, (*CPU).sec, false},
+	0x39: {"AND", modeAbsoluteY, 4, 1, (*CPU).and, false},
+	0x3A: {"NOP", modeImplied, 2, 0, (*CPU).nop, true},
+	0x3B: {"RLA", modeAbsoluteY, 7, 0, (*CPU).rla, true},
+	0x3C: {"NOP", modeAbsoluteX, 4, 1, (*CPU).nop, true},
+	0x3D: {"AND", modeAbsoluteX, 4, 1, (*CPU).and, false},
+	0x3E: {"ROL", modeAbsoluteX, 7, 0, (*CPU).rol, false},
+	0x3F: {"RLA", modeAbsoluteX, 7, 0, (*CPU).rla, true},
+	0x40: {"RTI", modeImplied, 6, 0, (*CPU).rti, false},
+	0x41: {"EOR", modeIndirectX, 6, 0, (*CPU).eor, false},
+	0x42: {"KIL", modeImplied, 2, 0, (*CPU).kil, true},
+	0x43: {"SRE", modeIndirectX, 8, 0, (*CPU).sre, true},
+	0x44: {"NOP", modeZeroPage, 3, 0, (*CPU).nop, true},
+	0x45: {"EOR", modeZeroPage, 3, 0, (*CPU).eor, false},
+	0x46: {"LSR", modeZeroPage, 5, 0, (*CPU).lsr, false},
+	0x47: {"SRE", modeZeroPage, 5, 0, (*CPU).sre, true},
+	0x48: {"PHA", modeImplied, 3, 0, (*CPU).pha, false},
+	0x49: {"EOR", modeImmediate, 2, 0, (*CPU).eor, false},
+	0x4A: {"LSR", modeAccumulator, 2, 0, (*CPU).lsrAccumulator, false},
+	0x4B: {"ALR", modeImmediate, 2, 0, (*CPU).alr, true},
+	0x4C: {"JMP", modeAbsolute, 3, 0, (*CPU).jmp, false},
+	0x4D: {"EOR", modeAbsolute, 4, 0, (*CPU).eor, false},
+	0x4E: {"LSR", modeAbsolute, 6, 0, (*CPU).lsr, false},
+	0x4F: {"SRE", modeAbsolute, 6, 0, (*CPU).sre, true},
+	0x50: {"BVC", modeRelative, 2, 0, (*CPU).bvc, false},
+	0x51: {"EOR", modeIndirectY, 5, 1, (*CPU).eor, false},
+	0x52: {"KIL", modeImplied, 2, 0, (*CPU).kil, true},
+	0x53: {"SRE", modeIndirectY, 8, 0, (*CPU).sre, true},
+	0x54: {"NOP", modeZeroPageX, 4, 0, (*CPU).nop, true},
+	0x55: {"EOR", modeZeroPageX, 4, 0, (*CPU).eor, false},
+	0x56: {"LSR", modeZeroPageX, 6, 0, (*CPU).lsr, false},
+	0x57: {"SRE", modeZeroPageX, 6, 0, (*CPU).sre, true},
+	0x58: {"CLI", modeImplied, 2, 0, (*CPU).cli, false},
+	0x59: {"EOR", modeAbsoluteY, 4, 1, (*CPU).eor, false},
+	0x5A: {"NOP", modeImplied, 2, 0, (*CPU).nop, true},
+	0x5B: {"SRE", modeAbsoluteY, 7, 0, (*CPU).sre, true},
+	0x5C: {"NOP", modeAbsoluteX, 4, 1, (*CPU).nop, true},
+	0x5D: {"EOR", modeAbsoluteX, 4, 1, (*CPU).eor, false},
+	0x5E: {"LSR", modeAbsoluteX, 7, 0, (*CPU).lsr, false},
+	0x5F: {"SRE", modeAbsoluteX, 7, 0, (*CPU).sre, true},
+	0x60: {"RTS", modeImplied, 6, 0, (*CPU).rts, false},
+	0x61: {"ADC", modeIndirectX, 6, 0, (*CPU).adc, false},
+	0x62: {"KIL", modeImplied, 2, 0, (*CPU).kil, true},
+	0x63: {"RRA", modeIndirectX, 8, 0, (*CPU).rra, true},
+	0x64: {"NOP", modeZeroPage, 3, 0, (*CPU).nop, true},
+	0x65: {"ADC", modeZeroPage, 3, 0, (*CPU).adc, false},
+	0x66: {"ROR", modeZeroPage, 5, 0, (*CPU).ror, false},
+	0x67: {"RRA", modeZeroPage, 5, 0, (*CPU).rra, true},
+	0x68: {"PLA", modeImplied, 4, 0, (*CPU).pla, false},
+	0x69: {"ADC", modeImmediate, 2, 0, (*CPU).adc, false},
+	0x6A: {"ROR", modeAccumulator, 2, 0, (*CPU).rorAccumulator, false},
+	0x6B: {"ARR", modeImmediate, 2, 0, (*CPU).arr, true},
+	0x6C: {"JMP", modeIndirect, 5, 0, (*CPU).jmp, false},
+	0x6D: {"ADC", modeAbsolute, 4, 0, (*CPU).adc, false},
+	0x6E: {"ROR", modeAbsolute, 6, 0, (*CPU).ror, false},
+	0x6F: {"RRA", modeAbsolute, 6, 0, (*CPU).rra, true},
+	0x70: {"BVS", modeRelative, 2, 0, (*CPU).bvs, false},
+	0x71: {"ADC", modeIndirectY, 5, 1, (*CPU).adc, false},
+	0x72: {"KIL", modeImplied, 2, 0, (*CPU).kil, true},
+	0x73: {"RRA", modeIndirectY, 8, 0, (*CPU).rra, true},
+	0x74: {"NOP", modeZeroPageX, 4, 0, (*CPU).nop, true},
+	0x75: {"ADC", modeZeroPageX, 4, 0, (*CPU).adc, false},
+	0x76: {"ROR", modeZeroPageX, 6, 0, (*CPU).ror, false},
+	0x77: {"RRA", modeZeroPageX, 6, 0, (*CPU).rra, true},
+	0x78: {"SEI", modeImplied, 2, 0, (*CPU).sei, false},
+	0x79: {"ADC", modeAbsoluteY, 4, 1, (*CPU).adc, false},
+	0x7A: {"NOP", modeImplied, 2, 0, (*CPU).nop, true},
+	0x7B: {"RRA", modeAbsoluteY, 7, 0, (*CPU).rra, true},
+	0x7C: {"NOP", modeAbsoluteX, 4, 1, (*CPU).nop, true},
+	0x7D: {"ADC", modeAbsoluteX, 4, 1, (*CPU).adc, false},
+	0x7E: {"ROR", modeAbsoluteX, 7, 0, (*CPU).ror, false},
+	0x7F: {"RRA", modeAbsoluteX, 7, 0, (*CPU).rra, true},
+	0x80: {"NOP", modeImmediate, 2, 0, (*CPU).nop, true},
+	0x81: {"STA", modeIndirectX, 6, 0, (*CPU).sta, false},
+	0x82: {"NOP", modeImmediate, 2, 0, (*CPU).nop, true},
+	0x83: {"SAX", modeIndirectX, 6, 0, (*CPU).sax, true},
+	0x84: {"STY", modeZeroPage, 3, 0, (*CPU).sty, false},
+	0x85: {"STA", modeZeroPage, 3, 0, (*CPU).sta, false},
+	0x86: {"STX", modeZeroPage, 3, 0, (*CPU).stx, false},
+	0x87: {"SAX", modeZeroPage, 3, 0, (*CPU).sax, true},
+	0x88: {"DEY", modeImplied, 2, 0, (*CPU).dey, false},
+	0x89: {"NOP", modeImmediate, 2, 0, (*CPU).nop, true},
+	0x8A: {"TXA", modeImplied, 2, 0, (*CPU).txa, false},
+	0x8B: {"XAA", modeImmediate, 2, 0, (*CPU).xaa, true},
+	0x8C: {"STY", modeAbsolute, 4, 0, (*CPU).sty, false},
+	0x8D: {"STA", modeAbsolute, 4, 0, (*CPU).sta, false},
+	0x8E: {"STX", modeAbsolute, 4, 0, (*CPU).stx, false},
+	0x8F: {"SAX", modeAbsolute, 4, 0, (*CPU).sax, true},
+	0x90: {"BCC", modeRelative, 2, 0, (*CPU).bcc, false},
+	0x91: {"STA", modeIndirectY, 6, 0, (*CPU).sta, false},
+	0x92: {"KIL", modeImplied, 2, 0, (*CPU).kil, true},
+	0x93: {"AHX", modeIndirectY, 6, 0, (*CPU).ahx, true},
+	0x94: {"STY", modeZeroPageX, 4, 0, (*CPU).sty, false},
+	0x95: {"STA", modeZeroPageX, 4, 0, (*CPU).sta, false},
+	0x96: {"STX", modeZeroPageY, 4, 0, (*CPU).stx, false},
+	0x97: {"SAX", modeZeroPageY, 4, 0, (*CPU).sax, true},
+	0x98: {"TYA", modeImplied, 2, 0, (*CPU).tya, false},
+	0x99: {"STA", modeAbsoluteY, 5, 0, (*CPU).sta, false},
+	0x9A: {"TXS", modeImplied, 2, 0, (*CPU).txs, false},
+	0x9B: {"TAS", modeAbsoluteY, 5, 0, (*CPU).tas, true},
+	0x9C: {"SHY", modeAbsoluteX, 5, 0, (*CPU).shy, true},
+	0x9D: {"STA", modeAbsoluteX, 5, 0, (*CPU).sta, false},
+	0x9E: {"SHX", modeAbsoluteY, 5, 0, (*CPU).shx, true},
+	0x9F: {"AHX", modeAbsoluteY, 5, 0, (*CPU).ahx, true},
+	0xA0: {"LDY", modeImmediate, 2, 0, (*CPU).ldy, false},
+	0xA1: {"LDA", modeIndirectX, 6, 0, (*CPU).lda, false},
+	0xA2: {"LDX", modeImmediate, 2, 0, (*CPU).ldx, false},
+	0xA3: {"LAX", modeIndirectX, 6, 0, (*CPU).lax, true},
+	0xA4: {"LDY", modeZeroPage, 3, 0, (*CPU).ldy, false},
+	0xA5: {"LDA", modeZeroPage, 3, 0, (*CPU).lda, false},
+	0xA6: {"LDX", modeZeroPage, 3, 0, (*CPU).ldx, false},
+	0xA7: {"LAX", modeZeroPage, 3, 0, (*CPU).lax, true},
+	0xA8: {"TAY", modeImplied, 2, 0, (*CPU).tay, false},
+	0xA9: {"LDA", modeImmediate, 2, 0, (*CPU).lda, false},
+	0xAA: {"TAX", modeImplied, 2, 0, (*CPU).tax, false},
+	0xAB: {"LAX", modeImmediate, 2, 0, (*CPU).lax, true},
+	0xAC: {"LDY", modeAbsolute, 4, 0, (*CPU).ldy, false},
+	0xAD: {"LDA", modeAbsolute, 4, 0, (*CPU).lda, false},
+	0xAE: {"LDX", modeAbsolute, 4, 0, (*CPU).ldx, false},
+	0xAF: {"LAX", modeAbsolute, 4, 0, (*CPU).lax, true},
+	0xB0: {"BCS", modeRelative, 2, 0, (*CPU).bcs, false},
+	0xB1: {"LDA", modeIndirectY, 5, 1, (*CPU).lda, false},
+	0xB2: {"KIL", modeImplied, 2, 0, (*CPU).kil, true},
+	0xB3: {"LAX", modeIndirectY, 5, 1, (*CPU).lax, true},
+	0xB4: {"LDY", modeZeroPageX, 4, 0, (*CPU).ldy, false},
+	0xB5: {"LDA", modeZeroPageX, 4, 0, (*CPU).lda, false},
+	0xB6: {"LDX", modeZeroPageY, 4, 0, (*CPU).ldx, false},
+	0xB7: {"LAX", modeZeroPageY, 4, 0, (*CPU).lax, true},
+	0xB8: {"CLV", modeImplied, 2, 0, (*CPU).clv, false},
+	0xB9: {"LDA", modeAbsoluteY, 4, 1, (*CPU).lda, false},
+	0xBA: {"TSX", modeImplied, 2, 0, (*CPU).tsx, false},
+	0xBB: {"LAS", modeAbsoluteY, 4, 1, (*CPU).las, true},
+	0xBC: {"LDY", modeAbsoluteX, 4, 1, (*CPU).ldy, false},
+	0xBD: {"LDA", modeAbsoluteX, 4, 1, (*CPU).lda, false},
+	0xBE: {"LDX", modeAbsoluteY, 4, 1, (*CPU).ldx, false},
+	0xBF: {"LAX", modeAbsoluteY, 4, 1, (*CPU).lax, true},
+	0xC0: {"CPY", modeImmediate, 2, 0, (*CPU).cpy, false},
+	0xC1: {"CMP", modeIndirectX, 6, 0, (*CPU).cmp, false},
+	0xC2: {"NOP", modeImmediate, 2, 0, (*CPU).nop, true},
+	0xC3: {"DCP", modeIndirectX, 8, 0, (*CPU).dcp, true},
+	0xC4: {"CPY", modeZeroPage, 3, 0, (*CPU).cpy, false},
+	0xC5: {"CMP", modeZeroPage, 3, 0, (*CPU).cmp, false},
+	0xC6: {"DEC", modeZeroPage, 5, 0, (*CPU).dec, false},
+	0xC7: {"DCP", modeZeroPage, 5, 0, (*CPU).dcp, true},
+	0xC8: {"INY", modeImplied, 2, 0, (*CPU).iny, false},
+	0xC9: {"CMP", modeImmediate, 2, 0, (*CPU).cmp, false},
+	0xCA: {"DEX", modeImplied, 2, 0, (*CPU).dex, false},
+	0xCB: {"AXS", modeImmediate, 2, 0, (*CPU).axs, true},
+	0xCC: {"CPY", modeAbsolute, 4, 0, (*CPU).cpy, false},
+	0xCD: {"CMP", modeAbsolute, 4, 0, (*CPU).cmp, false},
+	0xCE: {"DEC", modeAbsolute, 6, 0, (*CPU).dec, false},
+	0xCF: {"DCP", modeAbsolute, 6, 0, (*CPU).dcp, true},
+	0xD0: {"BNE", modeRelative, 2, 0, (*CPU).bne, false},
+	0xD1: {"CMP", modeIndirectY, 5, 1, (*CPU).cmp, false},
+	0xD2: {"KIL", modeImplied, 2, 0, (*CPU).kil, true},
+	0xD3: {"DCP", modeIndirectY, 8, 0, (*CPU).dcp, true},
+	0xD4: {"NOP", modeZeroPageX, 4, 0, (*CPU).nop, true},
+	0xD5: {"CMP", modeZeroPageX, 4, 0, (*CPU).cmp, false},
+	0xD6: {"DEC", modeZeroPageX, 6, 0, (*CPU).dec, false},
+	0xD7: {"DCP", modeZeroPageX, 6, 0, (*CPU).dcp, true},
+	0xD8: {"CLD", modeImplied, 2, 0, (*CPU).cld, false},
+	0xD9: {"CMP", modeAbsoluteY, 4, 1, (*CPU).cmp, false},
+	0xDA: {"NOP", modeImplied, 2, 0, (*CPU).nop, true},
+	0xDB: {"DCP", modeAbsoluteY, 7, 0, (*CPU).dcp, true},
+	0xDC: {"NOP", modeAbsoluteX, 4, 1, (*CPU).nop, true},
+	0xDD: {"CMP", modeAbsoluteX, 4, 1, (*CPU).cmp, false},
+	0xDE: {"DEC", modeAbsoluteX, 7, 0, (*CPU).dec, false},
+	0xDF: {"DCP", modeAbsoluteX, 7, 0, (*CPU).dcp, true},
+	0xE0: {"CPX", modeImmediate, 2, 0, (*CPU).cpx, false},
+	0xE1: {"SBC", modeIndirectX, 6, 0, (*CPU).sbc, false},
+	0xE2: {"NOP", modeImmediate, 2, 0, (*CPU).nop, true},
+	0xE3: {"ISB", modeIndirectX, 8, 0, (*CPU).isb, true},
+	0xE4: {"CPX", modeZeroPage, 3, 0, (*CPU).cpx, false},
+	0xE5: {"SBC", modeZeroPage, 3, 0, (*CPU).sbc, false},
+	0xE6: {"INC", modeZeroPage, 5, 0, (*CPU).inc, false},
+	0xE7: {"ISB", modeZeroPage, 5, 0, (*CPU).isb, true},
+	0xE8: {"INX", modeImplied, 2, 0, (*CPU).inx, false},
+	0xE9: {"SBC", modeImmediate, 2, 0, (*CPU).sbc, false},
+	0xEA: {"NOP", modeImplied, 2, 0, (*CPU).nop, false},
+	0xEB: {"SBC", modeImmediate, 2, 0, (*CPU).sbc, true},
+	0xEC: {"CPX", modeAbsolute, 4, 0, (*CPU).cpx, false},
+	0xED: {"SBC", modeAbsolute, 4, 0, (*CPU).sbc, false},
+	0xEE: {"INC", modeAbsolute, 6, 0, (*CPU).inc, false},
+	0xEF: {"ISB", modeAbsolute, 6, 0, (*CPU).isb, true},
+	0xF0: {"BEQ", modeRelative, 2, 0, (*CPU).beq, false},
+	0xF1: {"SBC", modeIndirectY, 5, 1, (*CPU).sbc, false},
+	0xF2: {"KIL", modeImplied, 2, 0, (*CPU).kil, true},
+	0xF3: {"ISB", modeIndirectY, 8, 0, (*CPU).isb, true},
+	0xF4: {"NOP", modeZeroPageX, 4, 0, (*CPU).nop, true},
+	0xF5: {"SBC", modeZeroPageX, 4, 0, (*CPU).sbc, false},
+	0xF6: {"INC", modeZeroPageX, 6, 0, (*CPU).inc, false},
+	0xF7: {"ISB", modeZeroPageX, 6, 0, (*CPU).isb, true},
+	0xF8: {"SED", modeImplied, 2, 0, (*CPU).sed, false},
+	0xF9: {"SBC", modeAbsoluteY, 4, 1, (*CPU).sbc, false},
+	0xFA: {"NOP", modeImplied, 2, 0, (*CPU).nop, true},
+	0xFB: {"ISB", modeAbsoluteY, 7, 0, (*CPU).isb, true},
+	0xFC: {"NOP", modeAbsoluteX, 4, 1, (*CPU).nop, true},
+	0xFD: {"SBC", modeAbsoluteX, 4, 1, (*CPU).sbc, false},
+	0xFE: {"INC", modeAbsoluteX, 7, 0, (*CPU).inc, false},
+	0xFF: {"ISB", modeAbsoluteX, 7, 0, (*CPU).isb, true},
+}
+
+func (cpu *CPU) setZN(value byte) {
+	cpu.zero = value == 0
+	cpu.negative = value&0x80 != 0
+}
+
+// Loads and stores.
+
+func (cpu *CPU) lda(addr uint16) {
+	cpu.accumulator = cpu.ram.ReadByte(addr)
+	cpu.setZN(cpu.accumulator)
+}
+
+func (cpu *CPU) ldx(addr uint16) {
+	cpu.x = cpu.ram.ReadByte(addr)
+	cpu.setZN(cpu.x)
+}
+
+func (cpu *CPU) ldy(addr uint16) {
+	cpu.y = cpu.ram.ReadByte(addr)
+	cpu.setZN(cpu.y)
+}
+
+func (cpu *CPU) sta(addr uint16) {
+	cpu.ram.WriteByte(addr, cpu.accumulator)
+}
+
+func (cpu *CPU) stx(addr uint16) {
+	cpu.ram.WriteByte(addr, cpu.x)
+}
+
+func (cpu *CPU) sty(addr uint16) {
+	cpu.ram.WriteByte(addr, cpu.y)
+}
+
+// Register transfers.
+
+func (cpu *CPU) tax(addr uint16) {
+	cpu.x = cpu.accumulator
+	cpu.setZN(cpu.x)
+}
+
+func (cpu *CPU) tay(addr uint16) {
+	cpu.y = cpu.accumulator
+	cpu.setZN(cpu.y)
+}
+
+func (cpu *CPU) txa(addr uint16) {
+	cpu.accumulator = cpu.x
+	cpu.setZN(cpu.accumulator)
+}
+
+func (cpu *CPU) tya(addr uint16) {
+	cpu.accumulator = cpu.y
+	cpu.setZN(cpu.accumulator)
+}
+
+func (cpu *CPU) tsx(addr uint16) {
+	cpu.x = cpu.sp
+	cpu.setZN(cpu.x)
+}
+
+func (cpu *CPU) txs(addr uint16) {
+	cpu.sp = cpu.x
+}
+
+// Stack.
+
+func (cpu *CPU) pha(addr uint16) {
+	cpu.stackPush(cpu.accumulator)
+}
+
+func (cpu *CPU) php(addr uint16) {
+	cpu.stackPush(cpu.statusPack(true))
+}
+
+func (cpu *CPU) pla(addr uint16) {
+	cpu.accumulator = cpu.stackPull()
+	cpu.setZN(cpu.accumulator)
+}
+
+func (cpu *CPU) plp(addr uint16) {
+	cpu.statusUnpack(cpu.stackPull())
+}
+
+// Logic and arithmetic.
+
+func (cpu *CPU) and(addr uint16) {
+	cpu.accumulator &= cpu.ram.ReadByte(addr)
+	cpu.setZN(cpu.accumulator)
+}
+
+func (cpu *CPU) eor(addr uint16) {
+	cpu.accumulator ^= cpu.ram.ReadByte(addr)
+	cpu.setZN(cpu.accumulator)
+}
+
+func (cpu *CPU) ora(addr uint16) {
+	cpu.accumulator |= cpu.ram.ReadByte(addr)
+	cpu.setZN(cpu.accumulator)
+}
+
+func (cpu *CPU) bit(addr uint16) {
+	data := cpu.ram.ReadByte(addr)
+	cpu.zero = cpu.accumulator&data == 0
+	cpu.overflow = data&0x40 != 0
+	cpu.negative = data&0x80 != 0
+}
+
+//add adds value and the carry to the accumulator. The NES has no decimal mode.
+func (cpu *CPU) add(value byte) {
+	sum := uint16(cpu.accumulator) + uint16(value)
+	if cpu.carry {
+		sum++
+	}
+	result := byte(sum)
+	cpu.carry = sum > 0xFF
+	cpu.overflow = (cpu.accumulator^result)&(value^result)&0x80 != 0
+	cpu.accumulator = result
+	cpu.setZN(result)
+}
+
+func (cpu *CPU) adc(addr uint16) {
+	cpu.add(cpu.ram.ReadByte(addr))
+}
+
+func (cpu *CPU) sbc(addr uint16) {
+	cpu.add(^cpu.ram.ReadByte(addr))
+}
+
+func (cpu *CPU) compare(register byte, value byte) {
+	cpu.carry = register >= value
+	cpu.setZN(register - value)
+}
+
+func (cpu *CPU) cmp(addr uint16) {
+	cpu.compare(cpu.accumulator, cpu.ram.ReadByte(addr))
+}
+
+func (cpu *CPU) cpx(addr uint16) {
+	cpu.compare(cpu.x, cpu.ram.ReadByte(addr))
+}
+
+func (cpu *CPU) cpy(addr uint16) {
+	cpu.compare(cpu.y, cpu.ram.ReadByte(addr))
+}
+
+// Increments and decrements.
+
+func (cpu *CPU) inc(addr uint16) {
+	data := cpu.ram.ReadByte(addr) + 1
+	cpu.ram.WriteByte(addr, data)
+	cpu.setZN(data)
+}
+
+func (cpu *CPU) dec(addr uint16) {
+	data := cpu.ram.ReadByte(addr) - 1
+	cpu.ram.WriteByte(addr, data)
+	cpu.setZN(data)
+}
+
+func (cpu *CPU) inx(addr uint16) {
+	cpu.x++
+	cpu.setZN(cpu.x)
+}
+
+func (cpu *CPU) iny(addr uint16) {
+	cpu.y++
+	cpu.setZN(cpu.y)
+}
+
+func (cpu *CPU) dex(addr uint16) {
+	cpu.x--
+	cpu.setZN(cpu.x)
+}
+
+func (cpu *CPU) dey(addr uint16) {
+	cpu.y--
+	cpu.setZN(cpu.y)
+}
+
+// Shifts. Each works on the accumulator or on memory, through the same helper.
+
+func (cpu *CPU) shiftLeft(value byte) byte {
+	cpu.carry = value&0x80 != 0
+	value <<= 1
+	cpu.setZN(value)
+	return value
+}
+
+func (cpu *CPU) shiftRight(value byte) byte {
+	cpu.carry = value&0x01 != 0
+	value >>= 1
+	cpu.setZN(value)
+	return value
+}
+
+func (cpu *CPU) rotateLeft(value byte) byte {
+	carry := cpu.carry
+	value = cpu.shiftLeft(value)
+	if carry {
+		value |= 0x01
+	}
+	cpu.setZN(value)
+	return value
+}
+
+func (cpu *CPU) rotateRight(value byte) byte {
+	carry := cpu.carry
+	value = cpu.shiftRight(value)
+	if carry {
+		value |= 0x80
+	}
+	cpu.setZN(value)
+	return value
+}
+
+//modify replaces the byte at addr with what operation makes of it and returns the new value.
+func (cpu *CPU) modify(addr uint16, operation func(*CPU, byte) byte) byte {
+	value := operation(cpu, cpu.ram.ReadByte(addr))
+	cpu.ram.WriteByte(addr, value)
+	return value
+}
+
+func (cpu *CPU) asl(addr uint16) {
+	cpu.modify(addr, (*CPU).shiftLeft)
+}
+
+func (cpu *CPU) lsr(addr uint16) {
+	cpu.modify(addr, (*CPU).shiftRight)
+}
+
+func (cpu *CPU) rol(addr uint16) {
+	cpu.modify(addr, (*CPU).rotateLeft)
+}
+
+func (cpu *CPU) ror(addr uint16) {
+	cpu.modify(addr, (*CPU).rotateRight)
+}
+
+func (cpu *CPU) aslAccumulator(addr uint16) {
+	cpu.accumulator = cpu.shiftLeft(cpu.accumulator)
+}
+
+func (cpu *CPU) lsrAccumulator(addr uint16) {
+	cpu.accumulator = cpu.shiftRight(cpu.accumulator)
+}
+
+func (cpu *CPU) rolAccumulator(addr uint16) {
+	cpu.accumulator = cpu.rotateLeft(cpu.accumulator)
+}
+
+func (cpu *CPU) rorAccumulator(addr uint16) {
+	cpu.accumulator = cpu.rotateRight(cpu.accumulator)
+}
+
+// Jumps and interrupts.
+
+func (cpu *CPU) jmp(addr uint16) {
+	cpu.pc = addr
+}
+
+func (cpu *CPU) jsr(addr uint16) {
+	returnAddr := cpu.pc - 1
+	cpu.stackPush(byte(returnAddr >> 8))
+	cpu.stackPush(byte(returnAddr))
+	cpu.pc = addr
+}
+
+func (cpu *CPU) rts(addr uint16) {
+	cpu.pc = (uint16(cpu.stackPull()) | uint16(cpu.stackPull())<<8) + 1
+}
+
+func (cpu *CPU) rti(addr uint16) {
+	cpu.statusUnpack(cpu.stackPull())
+	cpu.pc = uint16(cpu.stackPull()) | uint16(cpu.stackPull())<<8
+}
+
+//brk skips the byte after it, which is free for the handler to use.
+func (cpu *CPU) brk(addr uint16) {
+	returnAddr := cpu.pc + 1
+	cpu.stackPush(byte(returnAddr >> 8))
+	cpu.stackPush(byte(returnAddr))
+	cpu.stackPush(cpu.statusPack(true))
+	cpu.interruptEnabled = true
+	cpu.pc = cpu.getVectorBRK()
+}
+
+// Branches.
+
+func (cpu *CPU) branch(taken bool, addr uint16) {
+	if !taken {
+		return
+	}
+	cpu.cycles++
+	if cpu.pc&0xFF00 != addr&0xFF00 {
+		cpu.cycles++
+	}
+	cpu.pc = addr
+}
+
+func (cpu *CPU) bpl(addr uint16) {
+	cpu.branch(!cpu.negative, addr)
+}
+
+func (cpu *CPU) bmi(addr uint16) {
+	cpu.branch(cpu.negative, addr)
+}
+
+func (cpu *CPU) bvc(addr uint16) {
+	cpu.branch(!cpu.overflow, addr)
+}
+
+func (cpu *CPU) bvs(addr uint16) {
+	cpu.branch(cpu.overflow, addr)
+}
+
+func (cpu *CPU) bcc(addr uint16) {
+	cpu.branch(!cpu.carry, addr)
+}
+
+func (cpu *CPU) bcs(addr uint16) {
+	cpu.branch(cpu.carry, addr)
+}
+
+func (cpu *CPU) bne(addr uint16) {
+	cpu.branch(!cpu.zero, addr)
+}
+
+func (cpu *CPU) beq(addr uint16) {
+	cpu.branch(cpu.zero, addr)
+}
+
+// Flags.
+
+func (cpu *CPU) clc(addr uint16) {
+	cpu.carry = false
+}
+
+func (cpu *CPU) sec(addr uint16) {
+	cpu.carry = true
+}
+
+func (cpu *CPU) cli(addr uint16) {
+	cpu.interruptEnabled = false
+}
+
+func (cpu *CPU) sei(addr uint16) {
+	cpu.interruptEnabled = true
+}
+
+func (cpu *CPU) clv(addr uint16) {
+	cpu.overflow = false
+}
+
+func (cpu *CPU) cld(addr uint16) {
+	cpu.bcdEnabled = false
+}
+
+func (cpu *CPU) sed(addr uint16) {
+	cpu.bcdEnabled = true
+}
+
+//nop does nothing. The unofficial NOPs with an operand still take the time to fetch it.
+func (cpu *CPU) nop(addr uint16) {
+}
+
+// Unofficial opcodes. The stable ones combine two official instructions; the unstable
+// ones follow their usual behaviour and leave out the analog effects.
+
+//kil locks the CPU up, running the same opcode forever.
+func (cpu *CPU) kil(addr uint16) {
+	cpu.pc--
+}
+
+func (cpu *CPU) slo(addr uint16) {
+	cpu.accumulator |= cpu.modify(addr, (*CPU).shiftLeft)
+	cpu.setZN(cpu.accumulator)
+}
+
+func (cpu *CPU) rla(addr uint16) {
+	cpu.accumulator &= cpu.modify(addr, (*CPU).rotateLeft)
+	cpu.setZN(cpu.accumulator)
+}
+
+func (cpu *CPU) sre(addr uint16) {
+	cpu.accumulator ^= cpu.modify(addr, (*CPU).shiftRight)
+	cpu.setZN(cpu.accumulator)
+}
+
+func (cpu *CPU) rra(addr uint16) {
+	cpu.add(cpu.modify(addr, (*CPU).rotateRight))
+}
+
+func (cpu *CPU) dcp(addr uint16) {
+	data := cpu.ram.ReadByte(addr) - 1
+	cpu.ram.WriteByte(addr, data)
+	cpu.compare(cpu.accumulator, data)
+}
+
+func (cpu *CPU) isb(addr uint16) {
+	data := cpu.ram.ReadByte(addr) + 1
+	cpu.ram.WriteByte(addr, data)
+	cpu.add(^data)
+}
+
+func (cpu *CPU) lax(addr uint16) {
+	cpu.accumulator = cpu.ram.ReadByte(addr)
+	cpu.x = cpu.accumulator
+	cpu.setZN(cpu.x)
+}
+
+func (cpu *CPU) sax(addr uint16) {
+	cpu.ram.WriteByte(addr, cpu.accumulator&cpu.x)
+}
+
+func (cpu *CPU) anc(addr uint16) {
+	cpu.and(addr)
+	cpu.carry = cpu.negative
+}
+
+func (cpu *CPU) alr(addr uint16) {
+	cpu.accumulator &= cpu.ram.ReadByte(addr)
+	cpu.accumulator = cpu.shiftRight(cpu.accumulator)
+}
+
+func (cpu *CPU) arr(addr uint16) {
+	cpu.accumulator &= cpu.ram.ReadByte(addr)
+	cpu.accumulator = cpu.accumulator >> 1
+	if cpu.carry {
+		cpu.accumulator |= 0x80
+	}
+	cpu.setZN(cpu.accumulator)
+	cpu.carry = cpu.accumulator&0x40 != 0
+	cpu.overflow = (cpu.accumulator>>6^cpu.accumulator>>5)&1 != 0
+}
+
+func (cpu *CPU) axs(addr uint16) {
+	data := cpu.ram.ReadByte(addr)
+	value := cpu.accumulator & cpu.x
+	cpu.carry = value >= data
+	cpu.x = value - data
+	cpu.setZN(cpu.x)
+}
+
+func (cpu *CPU) xaa(addr uint16) {
+	cpu.accumulator = cpu.x & cpu.ram.ReadByte(addr)
+	cpu.setZN(cpu.accumulator)
+}
+
+func (cpu *CPU) las(addr uint16) {
+	value := cpu.ram.ReadByte(addr) & cpu.sp
+	cpu.accumulator, cpu.x, cpu.sp = value, value, value
+	cpu.setZN(value)
+}
+
+//storeHigh stores value ANDed with the high byte of addr plus one, as SHX, SHY, AHX and TAS do.
+func (cpu *CPU) storeHigh(addr uint16, value byte) {
+	cpu.ram.WriteByte(addr, value&(byte(addr>>8)+1))
+}
+
+func (cpu *CPU) shx(addr uint16) {
+	cpu.storeHigh(addr, cpu.x)
+}
+
+func (cpu *CPU) shy(addr uint16) {
+	cpu.storeHigh(addr, cpu.y)
+}
+
+func (cpu *CPU) ahx(addr uint16) {
+	cpu.storeHigh(addr, cpu.accumulator&cpu.x)
+}
+
+func (cpu *CPU) tas(addr uint16) {
+	cpu.sp = cpu.accumulator & cpu.x
+	cpu.storeHigh(addr, cpu.sp)
+}
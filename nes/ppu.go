@@ -1,4 +1,9 @@
-package main
+package nes
+
+import "encoding/gob"
+
+//vramSize covers the console's 2KB of nametable RAM plus the 2KB a four-screen cartridge adds.
+const vramSize = 4096
 
 //PPU Represents the state of the pixel processing unit
 type PPU struct {
@@ -6,7 +11,7 @@ type PPU struct {
 	funcPushPixel func(int, int, uint32)
 	funcPushFrame func()
 
-	vram          [2048]byte
+	vram          [vramSize]byte
 	oam           [256]byte
 	secondaryOam  [32]byte
 	palette       [32]byte
@@ -79,8 +84,112 @@ func (system *System) resetPPU() {
 	system.ppu.cpu = &system.cpu
 }
 
+func (ppu *PPU) Save(encoder *gob.Encoder) error {
+	return encodeAll(encoder,
+		ppu.vram,
+		ppu.oam,
+		ppu.secondaryOam,
+		ppu.palette,
+		ppu.warmupTicker,
+		ppu.scanlineCount,
+		ppu.tickCount,
+		ppu.frameCount,
+		ppu.cycles,
+		ppu.statusRendering,
+		ppu.vBlank,
+		ppu.sprite0Hit,
+		ppu.spriteOverflow,
+		ppu.ppuDataBuffer,
+		ppu.ppuLatch,
+		ppu.v,
+		ppu.t,
+		ppu.x,
+		ppu.w,
+		ppu.backgroundBitmapData,
+		ppu.spriteEvaluationN,
+		ppu.spriteEvaluationM,
+		ppu.spriteEvaluationRead,
+		ppu.pendingNumScanlineSprites,
+		ppu.numScanlineSprites,
+		ppu.spriteXPositions,
+		ppu.spriteAttributes,
+		ppu.spriteBitmapDataLo,
+		ppu.spriteBitmapDataHi,
+		ppu.spriteZeroAt,
+		ppu.spriteZeroAtNext,
+		ppu.baseNametable,
+		ppu.incrementVram,
+		ppu.spriteTableAddress,
+		ppu.backgroundTableAddress,
+		ppu.spriteSize,
+		ppu.masterSlave,
+		ppu.generateNonMaskableInterrupts,
+		ppu.grayscale,
+		ppu.showSpritesLeft,
+		ppu.showBackgroundLeft,
+		ppu.renderSprites,
+		ppu.renderBackground,
+		ppu.emphasizeRed,
+		ppu.emphasizeGreen,
+		ppu.emphasizeBlue,
+		ppu.oamAddr,
+	)
+}
+
+func (ppu *PPU) Load(decoder *gob.Decoder) error {
+	return decodeAll(decoder,
+		&ppu.vram,
+		&ppu.oam,
+		&ppu.secondaryOam,
+		&ppu.palette,
+		&ppu.warmupTicker,
+		&ppu.scanlineCount,
+		&ppu.tickCount,
+		&ppu.frameCount,
+		&ppu.cycles,
+		&ppu.statusRendering,
+		&ppu.vBlank,
+		&ppu.sprite0Hit,
+		&ppu.spriteOverflow,
+		&ppu.ppuDataBuffer,
+		&ppu.ppuLatch,
+		&ppu.v,
+		&ppu.t,
+		&ppu.x,
+		&ppu.w,
+		&ppu.backgroundBitmapData,
+		&ppu.spriteEvaluationN,
+		&ppu.spriteEvaluationM,
+		&ppu.spriteEvaluationRead,
+		&ppu.pendingNumScanlineSprites,
+		&ppu.numScanlineSprites,
+		&ppu.spriteXPositions,
+		&ppu.spriteAttributes,
+		&ppu.spriteBitmapDataLo,
+		&ppu.spriteBitmapDataHi,
+		&ppu.spriteZeroAt,
+		&ppu.spriteZeroAtNext,
+		&ppu.baseNametable,
+		&ppu.incrementVram,
+		&ppu.spriteTableAddress,
+		&ppu.backgroundTableAddress,
+		&ppu.spriteSize,
+		&ppu.masterSlave,
+		&ppu.generateNonMaskableInterrupts,
+		&ppu.grayscale,
+		&ppu.showSpritesLeft,
+		&ppu.showBackgroundLeft,
+		&ppu.renderSprites,
+		&ppu.renderBackground,
+		&ppu.emphasizeRed,
+		&ppu.emphasizeGreen,
+		&ppu.emphasizeBlue,
+		&ppu.oamAddr,
+	)
+}
+
 func (ppu *PPU) resetVRAM() {
-	for i := 0; i < 2048; i++ {
+	for i := 0; i < vramSize; i++ {
 		ppu.vram[i] = 0
 	}
 }
@@ -130,23 +239,28 @@ func (ppu *PPU) resetState() {
 func (memory *Memory) ReadPPU(addr uint16) byte {
 	// https://wiki.nesdev.com/w/index.php/PPU_memory_map
 	addr = addr & 0x3FFF
+	if memory.ppuObserver != nil {
+		memory.ppuObserver.ObservePPUBus(addr)
+	}
 	switch {
 	case addr <= 0x2FFF:
 		return memory.mapper.ReadByte(addr)
 	case addr <= 0x3EFF:
 		// mirrored from 0x2000
 		return memory.mapper.ReadByte(addr - 0x1000)
-	case addr <= 0x3FFF:
+	default:
 		// (only bottom 0x1F -- 5 bits)
 		index := addr & 0x1F
 		return memory.ppu.palette[index]
 	}
-	panic("Bad read on ppu!")
 }
 
 //WritePPU writes a byte to the PPU.
 func (memory *Memory) WritePPU(addr uint16, data byte) {
 	addr = addr & 0x3FFF
+	if memory.ppuObserver != nil {
+		memory.ppuObserver.ObservePPUBus(addr)
+	}
 	switch {
 	case addr <= 0x2FFF:
 		memory.mapper.WriteByte(addr, data)
@@ -191,7 +305,15 @@ func (ppu *PPU) resetPPUControl() {
 }
 
 func (ppu *PPU) resetPPUMask() {
-
+	ppu.grayscale = 0
+	ppu.showSpritesLeft = 0
+	ppu.showBackgroundLeft = 0
+	ppu.renderSprites = 0
+	ppu.renderBackground = 0
+	ppu.emphasizeRed = 0
+	ppu.emphasizeGreen = 0
+	ppu.emphasizeBlue = 0
+	ppu.oamAddr = 0
 }
 
 //ReadRegister reads a register from the PPU and returns the value.
@@ -312,8 +434,6 @@ func (ppu *PPU) WriteRegister(register int, data byte) {
 			data := ppu.cpu.memory.ReadByte(addr2)
 			ppu.oam[(ppu.oamAddr+byte(i))&0xFF] = data
 		}
-	default:
-		panic("Bad ppu register")
 	}
 }
 
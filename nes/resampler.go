@@ -0,0 +1,129 @@
+package nes
+
+import "math"
+
+const (
+	//resamplerTaps is the number of output samples each band-limited step is spread over.
+	resamplerTaps = 32
+	//resamplerPhases is the number of sub-sample positions the step kernel is tabulated for.
+	resamplerPhases = 64
+	//resamplerCutoff is the kernel's cutoff as a fraction of the output sample rate. With 32 taps the
+	//stop band starts just above Nyquist, so anything that could alias is removed.
+	resamplerCutoff = 0.45
+)
+
+//resamplerKernel holds, for every phase, the impulse that turns a level change into a band-limited step.
+var resamplerKernel [resamplerPhases][resamplerTaps]float64
+
+func init() {
+	half := resamplerTaps / 2
+	for phase := range resamplerKernel {
+		offset := float64(phase) / resamplerPhases
+		sum := 0.0
+		for tap := range resamplerKernel[phase] {
+			x := float64(tap-half) + 1 - offset
+			value := 2 * resamplerCutoff * sinc(2*resamplerCutoff*x) * blackman(x, float64(half))
+			resamplerKernel[phase][tap] = value
+			sum += value
+		}
+		// Normalize so a step always settles at exactly its height.
+		for tap := range resamplerKernel[phase] {
+			resamplerKernel[phase][tap] /= sum
+		}
+	}
+}
+
+func sinc(x float64) float64 {
+	if x == 0 {
+		return 1
+	}
+	return math.Sin(math.Pi*x) / (math.Pi * x)
+}
+
+//blackman is a Blackman window that falls to zero at -width and width.
+func blackman(x float64, width float64) float64 {
+	if x <= -width || x >= width {
+		return 0
+	}
+	t := math.Pi * x / width
+	return 0.42 + 0.5*math.Cos(t) + 0.08*math.Cos(2*t)
+}
+
+//Resampler converts a signal clocked at a high rate, like the APU's output every CPU cycle, into
+//samples at an audio rate. The APU's output is a series of steps, so rather than filtering every
+//input clock each change in level is added as a band-limited step, which costs nothing while the
+//level holds still. Output is delayed by half the kernel, resamplerTaps/2 samples.
+type Resampler struct {
+	clockRate  float64
+	sampleRate int
+	//step is the number of output samples per input clock.
+	step float64
+	//time is the position of the next input clock, in output samples from the start of buffer.
+	time float64
+	//level is the input level at the last clock.
+	level float64
+	//buffer holds the differences between consecutive output samples that aren't read yet.
+	buffer []float64
+	//integrator is the value of the last sample read.
+	integrator float64
+}
+
+//NewResampler returns a resampler from clockRate input clocks per second to sampleRate samples per second.
+func NewResampler(clockRate float64, sampleRate int) *Resampler {
+	return &Resampler{
+		clockRate:  clockRate,
+		sampleRate: sampleRate,
+		step:       float64(sampleRate) / clockRate,
+		buffer:     make([]float64, resamplerTaps),
+	}
+}
+
+//SampleRate returns the output sample rate.
+func (resampler *Resampler) SampleRate() int {
+	return resampler.sampleRate
+}
+
+//Clock feeds the input level for one clock.
+func (resampler *Resampler) Clock(level float32) {
+	if delta := float64(level) - resampler.level; delta != 0 {
+		resampler.addDelta(delta)
+		resampler.level = float64(level)
+	}
+	resampler.time += resampler.step
+}
+
+func (resampler *Resampler) addDelta(delta float64) {
+	whole := int(resampler.time)
+	for len(resampler.buffer) < whole+resamplerTaps {
+		resampler.buffer = append(resampler.buffer, 0)
+	}
+	phase := int((resampler.time - float64(whole)) * resamplerPhases)
+	out := resampler.buffer[whole : whole+resamplerTaps]
+	for tap, value := range resamplerKernel[phase] {
+		out[tap] += delta * value
+	}
+}
+
+//Available returns the number of samples that no future input can change any more.
+func (resampler *Resampler) Available() int {
+	return int(resampler.time)
+}
+
+//Read fills out with up to Available samples and returns how many it wrote.
+func (resampler *Resampler) Read(out []float32) int {
+	count := resampler.Available()
+	if count > len(out) {
+		count = len(out)
+	}
+	for len(resampler.buffer) < count+resamplerTaps {
+		resampler.buffer = append(resampler.buffer, 0)
+	}
+	for i := 0; i < count; i++ {
+		resampler.integrator += resampler.buffer[i]
+		out[i] = float32(resampler.integrator)
+	}
+	remaining := copy(resampler.buffer, resampler.buffer[count:])
+	resampler.buffer = resampler.buffer[:remaining]
+	resampler.time -= float64(count)
+	return count
+}
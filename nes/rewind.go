@@ -0,0 +1,250 @@
+package nes
+
+import (
+	"bytes"
+	"compress/flate"
+	"encoding/binary"
+	"io"
+)
+
+const (
+	//defaultKeyframeEvery is how many snapshots share one keyframe.
+	defaultKeyframeEvery = 30
+	//deltaChunk is how much of a snapshot is deflated at a time against its keyframe.
+	deltaChunk = 16384
+	//deltaSlack is how far the keyframe dictionary reaches either side of a chunk, to cover
+	//fields that moved when gob's variable length integers changed size.
+	deltaSlack = 4096
+)
+
+//rewindGroup is a keyframe and the snapshots delta encoded against it.
+//Deflate only looks 32KB back, so a delta is deflated in chunks, each with the same region of
+//the keyframe as its preset dictionary. Anything unchanged since the keyframe then costs a
+//back reference however large the state is.
+type rewindGroup struct {
+	keyframe []byte
+	deltas   [][]byte
+}
+
+func (group *rewindGroup) size() int {
+	size := len(group.keyframe)
+	for _, delta := range group.deltas {
+		size += len(delta)
+	}
+	return size
+}
+
+//Rewinder keeps a bounded history of compressed snapshots so a System can be played backwards.
+type Rewinder struct {
+	system *System
+	//Interval is the number of frames between snapshots.
+	Interval int
+	//KeyframeEvery is the number of snapshots that share one keyframe.
+	KeyframeEvery int
+	//Budget is the most memory, in bytes, the compressed history may use.
+	Budget int
+
+	groups   []*rewindGroup
+	size     int
+	frames   int
+	keyframe []byte
+}
+
+//NewRewinder returns a rewinder that snapshots system every interval frames within budget bytes.
+func NewRewinder(system *System, interval int, budget int) *Rewinder {
+	if interval < 1 {
+		interval = 1
+	}
+	return &Rewinder{
+		system:        system,
+		Interval:      interval,
+		KeyframeEvery: defaultKeyframeEvery,
+		Budget:        budget,
+	}
+}
+
+//StepFrame emulates one frame with System.EmulateFrame, taking a snapshot every Interval frames.
+func (rewinder *Rewinder) StepFrame() int {
+	cycles := rewinder.system.EmulateFrame()
+	rewinder.frames++
+	if rewinder.frames%rewinder.Interval == 0 {
+		rewinder.snapshot()
+	}
+	return cycles
+}
+
+//Rewind steps back the given number of snapshots and reports whether any history was left.
+//One frame is emulated after loading so the framebuffer shows the restored moment.
+func (rewinder *Rewinder) Rewind(snapshots int) bool {
+	var state []byte
+	for i := 0; i < snapshots; i++ {
+		previous := rewinder.pop()
+		if previous == nil {
+			break
+		}
+		state = previous
+	}
+	if state == nil {
+		return false
+	}
+	if err := rewinder.system.LoadState(bytes.NewReader(state)); err != nil {
+		return false
+	}
+	rewinder.system.EmulateFrame()
+	rewinder.frames = 0
+	return true
+}
+
+//Len returns the number of snapshots available to rewind through.
+func (rewinder *Rewinder) Len() int {
+	length := 0
+	for _, group := range rewinder.groups {
+		length += 1 + len(group.deltas)
+	}
+	return length
+}
+
+//Size returns the memory, in bytes, used by the compressed history.
+func (rewinder *Rewinder) Size() int {
+	return rewinder.size
+}
+
+//Reset throws away the history, for instance after loading a state or a new ROM.
+func (rewinder *Rewinder) Reset() {
+	rewinder.groups = nil
+	rewinder.size = 0
+	rewinder.frames = 0
+	rewinder.keyframe = nil
+}
+
+func (rewinder *Rewinder) snapshot() {
+	var state bytes.Buffer
+	if err := rewinder.system.SaveState(&state); err != nil {
+		return
+	}
+
+	var delta []byte
+	if last := len(rewinder.groups) - 1; last >= 0 && len(rewinder.groups[last].deltas)+1 < rewinder.KeyframeEvery {
+		delta = compressDelta(state.Bytes(), rewinder.newestKeyframe())
+		// A group has to fit in the budget on its own, or trimming would throw it all away.
+		if rewinder.groups[last].size()+len(delta) > rewinder.Budget {
+			delta = nil
+		}
+	}
+	if delta != nil {
+		group := rewinder.groups[len(rewinder.groups)-1]
+		group.deltas = append(group.deltas, delta)
+		rewinder.size += len(delta)
+	} else {
+		group := &rewindGroup{keyframe: compressState(state.Bytes(), nil)}
+		rewinder.groups = append(rewinder.groups, group)
+		rewinder.keyframe = state.Bytes()
+		rewinder.size += len(group.keyframe)
+	}
+
+	// Drop whole groups from the oldest end, since deltas are useless without their keyframe.
+	for rewinder.size > rewinder.Budget && len(rewinder.groups) > 0 {
+		rewinder.size -= rewinder.groups[0].size()
+		rewinder.groups[0] = nil
+		rewinder.groups = rewinder.groups[1:]
+	}
+	if len(rewinder.groups) == 0 {
+		rewinder.keyframe = nil
+	}
+}
+
+//pop removes the newest snapshot and returns it decompressed, or nil if there are none.
+func (rewinder *Rewinder) pop() []byte {
+	last := len(rewinder.groups) - 1
+	if last < 0 {
+		return nil
+	}
+	group := rewinder.groups[last]
+	if count := len(group.deltas); count > 0 {
+		delta := group.deltas[count-1]
+		group.deltas = group.deltas[:count-1]
+		rewinder.size -= len(delta)
+		return decompressDelta(delta, rewinder.newestKeyframe())
+	}
+
+	rewinder.groups = rewinder.groups[:last]
+	rewinder.size -= len(group.keyframe)
+	rewinder.keyframe = nil
+	return decompressState(group.keyframe, nil)
+}
+
+//newestKeyframe returns the uncompressed keyframe of the newest group.
+func (rewinder *Rewinder) newestKeyframe() []byte {
+	if rewinder.keyframe == nil {
+		rewinder.keyframe = decompressState(rewinder.groups[len(rewinder.groups)-1].keyframe, nil)
+	}
+	return rewinder.keyframe
+}
+
+func compressState(state []byte, dictionary []byte) []byte {
+	var compressed bytes.Buffer
+	writer, _ := flate.NewWriterDict(&compressed, flate.BestSpeed, dictionary)
+	writer.Write(state)
+	writer.Close()
+	return compressed.Bytes()
+}
+
+func decompressState(compressed []byte, dictionary []byte) []byte {
+	reader := flate.NewReaderDict(bytes.NewReader(compressed), dictionary)
+	defer reader.Close()
+	state, err := io.ReadAll(reader)
+	if err != nil {
+		return nil
+	}
+	return state
+}
+
+//compressDelta deflates state in chunks against the matching regions of keyframe.
+//Each chunk is stored after its length.
+func compressDelta(state []byte, keyframe []byte) []byte {
+	var delta []byte
+	for start := 0; start < len(state); start += deltaChunk {
+		end := start + deltaChunk
+		if end > len(state) {
+			end = len(state)
+		}
+		chunk := compressState(state[start:end], deltaDictionary(keyframe, start))
+		delta = binary.AppendUvarint(delta, uint64(len(chunk)))
+		delta = append(delta, chunk...)
+	}
+	return delta
+}
+
+//decompressDelta reverses compressDelta, returning nil if the delta is corrupt.
+func decompressDelta(delta []byte, keyframe []byte) []byte {
+	var state []byte
+	for len(delta) > 0 {
+		length, n := binary.Uvarint(delta)
+		if n <= 0 || length > uint64(len(delta)-n) {
+			return nil
+		}
+		chunk := decompressState(delta[n:n+int(length)], deltaDictionary(keyframe, len(state)))
+		if chunk == nil {
+			return nil
+		}
+		state = append(state, chunk...)
+		delta = delta[n+int(length):]
+	}
+	return state
+}
+
+//deltaDictionary returns the part of keyframe used as the dictionary for the chunk at start.
+func deltaDictionary(keyframe []byte, start int) []byte {
+	from := start - deltaSlack
+	if from < 0 {
+		from = 0
+	}
+	to := start + deltaChunk + deltaSlack
+	if to > len(keyframe) {
+		to = len(keyframe)
+	}
+	if from > to {
+		from = to
+	}
+	return keyframe[from:to]
+}
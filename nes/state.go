@@ -0,0 +1,194 @@
+package nes
+
+import (
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"io"
+)
+
+const (
+	stateMagic   = "NesGo save state"
+	stateVersion = 7
+)
+
+var (
+	//ErrBadState is returned when a save state is corrupt or not a save state at all.
+	ErrBadState = errors.New("nes: not a valid save state")
+	//ErrStateVersion is returned when a save state was written by an incompatible version.
+	ErrStateVersion = errors.New("nes: unsupported save state version")
+	//ErrStateROMMismatch is returned when a save state belongs to a different ROM.
+	ErrStateROMMismatch = errors.New("nes: save state was made with a different ROM")
+)
+
+//stateWriter remembers the first error from the underlying writer.
+type stateWriter struct {
+	w   io.Writer
+	err error
+}
+
+func (writer *stateWriter) Write(p []byte) (int, error) {
+	if writer.err != nil {
+		return 0, writer.err
+	}
+	n, err := writer.w.Write(p)
+	writer.err = err
+	return n, err
+}
+
+//stateReader remembers the first error from the underlying reader.
+type stateReader struct {
+	r   io.Reader
+	err error
+}
+
+func (reader *stateReader) Read(p []byte) (int, error) {
+	if reader.err != nil {
+		return 0, reader.err
+	}
+	n, err := reader.r.Read(p)
+	if err != nil && err != io.EOF {
+		reader.err = err
+	}
+	return n, err
+}
+
+//encodeAll encodes values in order and stops at the first error.
+func encodeAll(encoder *gob.Encoder, values ...interface{}) error {
+	for _, value := range values {
+		if err := encoder.Encode(value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+//decodeAll decodes into values in order and stops at the first error.
+func decodeAll(decoder *gob.Decoder, values ...interface{}) error {
+	for _, value := range values {
+		if err := decoder.Decode(value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+//saveAll saves each part in order and stops at the first error.
+func saveAll(encoder *gob.Encoder, savers ...StateSaver) error {
+	for _, saver := range savers {
+		if err := saver.Save(encoder); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+//loadAll loads each part in the order saveAll saved them and stops at the first error.
+func loadAll(decoder *gob.Decoder, savers ...StateSaver) error {
+	for _, saver := range savers {
+		if err := saver.Load(decoder); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+//stateParts lists what goes into a save state after the header, in order.
+func (system *System) stateParts() []StateSaver {
+	parts := []StateSaver{&system.cpu, &system.ppu, &system.apu, &system.memory}
+	for i := range system.controller {
+		parts = append(parts, &system.controller[i])
+	}
+	parts = append(parts, system.inputDeviceParts()...)
+	parts = append(parts, system.cartridge)
+	if system.memory.stateSaver != nil {
+		parts = append(parts, system.memory.stateSaver)
+	}
+	if system.memory.audio != nil {
+		parts = append(parts, audioState{system.memory.audio})
+	}
+	return parts
+}
+
+//audioState saves an expansion chip as a part of its own, after the board.
+type audioState struct {
+	AudioExpansion
+}
+
+func (audio audioState) Save(encoder *gob.Encoder) error {
+	return audio.SaveAudio(encoder)
+}
+
+func (audio audioState) Load(decoder *gob.Decoder) error {
+	return audio.LoadAudio(decoder)
+}
+
+//SaveState writes everything needed to resume emulation from this exact cycle.
+func (system *System) SaveState(w io.Writer) error {
+	if system.cartridge == nil {
+		return ErrNoCartridge
+	}
+	writer := &stateWriter{w: w}
+	encoder := gob.NewEncoder(writer)
+	err := encodeAll(encoder, stateMagic, stateVersion, system.cartridge.hash)
+	if err == nil {
+		err = saveAll(encoder, system.stateParts()...)
+	}
+	if err == nil {
+		// The trailer catches states whose sections went out of step.
+		err = encoder.Encode(stateMagic)
+	}
+	if writer.err != nil {
+		return writer.err
+	}
+	if err != nil {
+		return fmt.Errorf("nes: saving state: %w", err)
+	}
+	return nil
+}
+
+//LoadState restores a state written by SaveState for the inserted ROM.
+//The header is checked before anything is modified; if the body turns out to be corrupt
+//an error is returned and the system should be reset or loaded again.
+func (system *System) LoadState(r io.Reader) error {
+	if system.cartridge == nil {
+		return ErrNoCartridge
+	}
+	reader := &stateReader{r: r}
+	decoder := gob.NewDecoder(reader)
+
+	var magic string
+	if err := decoder.Decode(&magic); err != nil || magic != stateMagic {
+		return ErrBadState
+	}
+	var version int
+	if err := decoder.Decode(&version); err != nil {
+		return ErrBadState
+	}
+	if version != stateVersion {
+		return ErrStateVersion
+	}
+	var hash [len(system.cartridge.hash)]byte
+	if err := decoder.Decode(&hash); err != nil {
+		return ErrBadState
+	}
+	if hash != system.cartridge.hash {
+		return ErrStateROMMismatch
+	}
+
+	if err := loadAll(decoder, system.stateParts()...); err != nil {
+		if reader.err != nil {
+			return reader.err
+		}
+		return fmt.Errorf("%w: %v", ErrBadState, err)
+	}
+
+	magic = ""
+	if err := decoder.Decode(&magic); err != nil || magic != stateMagic {
+		if reader.err != nil {
+			return reader.err
+		}
+		return ErrBadState
+	}
+	return nil
+}
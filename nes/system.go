@@ -0,0 +1,170 @@
+package nes
+
+import "io"
+
+const (
+	//Width of the rendered picture in pixels.
+	Width = 256
+	//Height of the rendered picture in pixels.
+	Height = 240
+)
+
+//System represents the entire NES system.
+type System struct {
+	memory      Memory
+	cpu         CPU
+	ppu         PPU
+	apu         APU
+	mixer       Mixer
+	controller  [4]Controller
+	input       inputPorts
+	multitap    int
+	cartridge   *Cartridge
+	framebuffer [Width * Height]uint32
+}
+
+//ResetSystem resets the system struct. This is equivalent to pressing reset.
+func (system *System) ResetSystem() error {
+	system.resetCPU()
+	system.resetAPU()
+	system.resetMemory()
+	system.cpu.memory = &system.memory
+	system.resetPPU()
+	system.resetControllers()
+	if err := system.resetCartridge(); err != nil {
+		return err
+	}
+	system.cpu.pc = system.cpu.getVectorReset()
+	return nil
+}
+
+//PowerCycle switches the system off and on again. Unlike ResetSystem this also clears the
+//cartridge's CHR-RAM and PRG-RAM, except PRG-RAM that is battery backed.
+func (system *System) PowerCycle() error {
+	return system.powerCycle(false)
+}
+
+//powerCycle is PowerCycle, clearing battery backed PRG-RAM too when clearBattery is set.
+func (system *System) powerCycle(clearBattery bool) error {
+	if system.cartridge == nil {
+		return ErrNoCartridge
+	}
+	if clearBattery || !system.cartridge.header.BatteryBacked {
+		for i := range system.cartridge.prgRAM {
+			system.cartridge.prgRAM[i] = 0
+		}
+	}
+	if system.cartridge.header.SizeRomCHR == 0 {
+		for i := range system.cartridge.chr {
+			system.cartridge.chr[i] = 0
+		}
+	}
+	return system.ResetSystem()
+}
+
+//New returns a new system with no cartridge inserted.
+func New() *System {
+	system := &System{}
+	system.mixer.Reset()
+	system.input.devices[0] = &system.controller[0]
+	system.input.devices[1] = &system.controller[1]
+	system.ppu.funcPushPixel = system.pushPixel
+	system.ppu.funcPushFrame = func() {}
+	return system
+}
+
+//LoadROM reads an iNES image from r, inserts it and powers on the system.
+func (system *System) LoadROM(r io.Reader) error {
+	cartridge, err := LoadROM(r)
+	if err != nil {
+		return err
+	}
+	return system.InsertCartridge(cartridge)
+}
+
+//InsertCartridge inserts a loaded cartridge and powers on the system.
+func (system *System) InsertCartridge(cartridge *Cartridge) error {
+	system.cartridge = cartridge
+	return system.ResetSystem()
+}
+
+//Emulate starts the system clock.
+func (system *System) Emulate() int {
+	cpuCycles := system.cpu.Emulate(1)
+	ppuClocks := cpuCycles * 3
+	for i := 0; i < ppuClocks; i++ {
+		system.ppu.Emulate(1)
+		system.memory.mapper.Emulate()
+		if system.memory.irqSource != nil && system.memory.irqSource.IRQPending() {
+			system.cpu.triggerInterruptIRQ()
+		}
+	}
+
+	for i := 0; i < cpuCycles; i++ {
+		system.apu.Step()
+		if system.apu.irqPending() {
+			system.cpu.triggerInterruptIRQ()
+		}
+	}
+	return cpuCycles
+}
+
+//EmulateFrame emulates one frame of the ssytem.
+func (system *System) EmulateFrame() int {
+	cycles := 0
+	startFrame := system.ppu.frameCount
+	for startFrame == system.ppu.frameCount {
+		cycles += system.Emulate()
+	}
+	for i := range system.controller {
+		system.controller[i].stepTurbo()
+	}
+	system.apu.flushSamples()
+	return cycles
+}
+
+//StepFrame emulates until the next frame has been rendered and returns the CPU cycles spent.
+func (system *System) StepFrame() int {
+	return system.EmulateFrame()
+}
+
+//Framebuffer returns the last rendered picture as Width*Height 0xRRGGBB pixels.
+func (system *System) Framebuffer() []uint32 {
+	return system.framebuffer[:]
+}
+
+//SetButtons sets the pressed state of every button on the controller in the given port.
+//Ports 2 and 3 are players 3 and 4, connected through SetMultitap.
+func (system *System) SetButtons(port int, buttons [8]bool) {
+	system.controller[port].buttons = buttons
+}
+
+//SetTurbo sets the buttons of the controller in port that autofire while held. Autofire is
+//counted in emulated frames, so a run with the same input always presses on the same frames.
+func (system *System) SetTurbo(port int, turbo [8]bool) {
+	system.controller[port].turbo = turbo
+}
+
+//SetTurboRate sets the autofire period of the controller in port, in frames per press,
+//clamped to MinTurboRate..MaxTurboRate.
+func (system *System) SetTurboRate(port int, rate int) {
+	if rate < MinTurboRate {
+		rate = MinTurboRate
+	}
+	if rate > MaxTurboRate {
+		rate = MaxTurboRate
+	}
+	system.controller[port].turboRate = rate
+}
+
+//TurboRate returns the autofire period of the controller in port.
+func (system *System) TurboRate(port int) int {
+	return system.controller[port].rate()
+}
+
+func (system *System) pushPixel(x int, y int, col uint32) {
+	system.framebuffer[y*Width+x] = col
+	for _, sensor := range system.input.sensors {
+		sensor.observePixel(x, y, col)
+	}
+}
@@ -0,0 +1,112 @@
+package nes
+
+import "encoding/gob"
+
+const (
+	//VausMin is the potentiometer value with the Vaus knob turned fully left.
+	VausMin = 0x62
+	//VausMax is the potentiometer value with the knob turned fully right.
+	VausMax = 0xF2
+)
+
+//Vaus is Taito's Arkanoid controller, a knob on an 8-bit potentiometer and a fire button.
+//The NES version plugs into a controller port and is read through D3 (fire) and D4 (knob);
+//the Famicom version plugs into the expansion port and puts fire on D1 of $4016 and the knob
+//on D1 of $4017. Either way the strobe latches the knob, which then shifts out inverted, MSB first.
+type Vaus struct {
+	famicom  bool
+	position int
+	fire     bool
+	shift    byte
+	strobe   byte
+}
+
+//NewVaus returns a Vaus with its knob centred: the Famicom expansion port version if famicom
+//is set, otherwise the NES controller port version.
+func NewVaus(famicom bool) *Vaus {
+	return &Vaus{famicom: famicom, position: (VausMin + VausMax) / 2}
+}
+
+//SetPosition turns the knob, clamped to VausMin..VausMax.
+func (vaus *Vaus) SetPosition(position int) {
+	if position < VausMin {
+		position = VausMin
+	}
+	if position > VausMax {
+		position = VausMax
+	}
+	vaus.position = position
+}
+
+//Position returns the knob's potentiometer value.
+func (vaus *Vaus) Position() int {
+	return vaus.position
+}
+
+//SetFire presses or releases the fire button.
+func (vaus *Vaus) SetFire(pressed bool) {
+	vaus.fire = pressed
+}
+
+//Read returns the fire button and the next bit of the knob.
+func (vaus *Vaus) Read(port int) byte {
+	var fire, knob byte
+	if vaus.fire {
+		fire = 1
+	}
+	knob = vaus.shift >> 7
+	if vaus.famicom {
+		if port == 0 {
+			return fire << 1
+		}
+		vaus.clock()
+		return knob << 1
+	}
+	vaus.clock()
+	return fire<<3 | knob<<4
+}
+
+func (vaus *Vaus) clock() {
+	if vaus.strobe&1 == 0 {
+		vaus.shift <<= 1
+	}
+}
+
+//Write latches the knob while the strobe, bit 0, is high.
+func (vaus *Vaus) Write(out byte) {
+	vaus.strobe = out
+	if out&1 == 1 {
+		vaus.shift = ^byte(vaus.position)
+	}
+}
+
+func (vaus *Vaus) Save(encoder *gob.Encoder) error {
+	return encodeAll(encoder, vaus.shift, vaus.strobe)
+}
+
+func (vaus *Vaus) Load(decoder *gob.Decoder) error {
+	return decodeAll(decoder, &vaus.shift, &vaus.strobe)
+}
+
+//MovieName identifies the Vaus in movies.
+func (vaus *Vaus) MovieName() string {
+	if vaus.famicom {
+		return "vaus-famicom"
+	}
+	return "vaus"
+}
+
+//MovieInput records the knob and the fire button.
+func (vaus *Vaus) MovieInput(input *[MovieInputSize]byte) {
+	input[0] = byte(vaus.position)
+	input[1] = 0
+	if vaus.fire {
+		input[1] = 1
+	}
+}
+
+//SetMovieInput plays back input recorded by MovieInput.
+func (vaus *Vaus) SetMovieInput(input *[MovieInputSize]byte) {
+	vaus.SetPosition(int(input[0]))
+	vaus.fire = input[1] != 0
+}
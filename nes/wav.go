@@ -0,0 +1,122 @@
+package nes
+
+import (
+	"encoding/binary"
+	"io"
+	"math"
+)
+
+const wavHeaderSize = 44
+
+//WAVWriter is an AudioSink that writes 16-bit mono PCM WAV files.
+//Samples are clipped to -1..1. The sizes in the header are filled in by Close.
+type WAVWriter struct {
+	w          io.WriteSeeker
+	sampleRate int
+	length     int
+	buffer     []byte
+	err        error
+}
+
+//NewWAVWriter writes a WAV header to w and returns a sink that appends samples to it.
+func NewWAVWriter(w io.WriteSeeker, sampleRate int) (*WAVWriter, error) {
+	writer := &WAVWriter{w: w, sampleRate: sampleRate}
+	if err := writer.writeHeader(); err != nil {
+		return nil, err
+	}
+	return writer, nil
+}
+
+func (writer *WAVWriter) writeHeader() error {
+	var header [wavHeaderSize]byte
+	copy(header[0:], "RIFF")
+	binary.LittleEndian.PutUint32(header[4:], uint32(wavHeaderSize-8+writer.length*2))
+	copy(header[8:], "WAVEfmt ")
+	binary.LittleEndian.PutUint32(header[16:], 16)
+	binary.LittleEndian.PutUint16(header[20:], 1) // PCM
+	binary.LittleEndian.PutUint16(header[22:], 1) // Mono
+	binary.LittleEndian.PutUint32(header[24:], uint32(writer.sampleRate))
+	binary.LittleEndian.PutUint32(header[28:], uint32(writer.sampleRate*2))
+	binary.LittleEndian.PutUint16(header[32:], 2)
+	binary.LittleEndian.PutUint16(header[34:], 16)
+	copy(header[36:], "data")
+	binary.LittleEndian.PutUint32(header[40:], uint32(writer.length*2))
+	_, err := writer.w.Write(header[:])
+	return err
+}
+
+//SampleRate returns the rate written in the header.
+func (writer *WAVWriter) SampleRate() int {
+	return writer.sampleRate
+}
+
+//WriteSamples appends samples to the file. Errors are kept and returned by Close.
+func (writer *WAVWriter) WriteSamples(samples []float32) {
+	if writer.err != nil {
+		return
+	}
+	if cap(writer.buffer) < len(samples)*2 {
+		writer.buffer = make([]byte, len(samples)*2)
+	}
+	buffer := writer.buffer[:len(samples)*2]
+	for i, sample := range samples {
+		sample = float32(math.Max(-1, math.Min(1, float64(sample))))
+		binary.LittleEndian.PutUint16(buffer[i*2:], uint16(int16(sample*math.MaxInt16)))
+	}
+	_, writer.err = writer.w.Write(buffer)
+	writer.length += len(samples)
+}
+
+//Close fills in the header's sizes. It does not close the underlying writer.
+func (writer *WAVWriter) Close() error {
+	if writer.err != nil {
+		return writer.err
+	}
+	if _, err := writer.w.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	if err := writer.writeHeader(); err != nil {
+		return err
+	}
+	_, err := writer.w.Seek(0, io.SeekEnd)
+	return err
+}
+
+//RawWriter is an AudioSink that writes samples as headerless little-endian 32-bit floats,
+//exactly as the APU produced them.
+type RawWriter struct {
+	w          io.Writer
+	sampleRate int
+	buffer     []byte
+	err        error
+}
+
+//NewRawWriter returns a sink that writes raw samples to w.
+func NewRawWriter(w io.Writer, sampleRate int) *RawWriter {
+	return &RawWriter{w: w, sampleRate: sampleRate}
+}
+
+//SampleRate returns the rate the writer was created with.
+func (writer *RawWriter) SampleRate() int {
+	return writer.sampleRate
+}
+
+//WriteSamples appends samples to the output. Errors are kept and returned by Close.
+func (writer *RawWriter) WriteSamples(samples []float32) {
+	if writer.err != nil {
+		return
+	}
+	if cap(writer.buffer) < len(samples)*4 {
+		writer.buffer = make([]byte, len(samples)*4)
+	}
+	buffer := writer.buffer[:len(samples)*4]
+	for i, sample := range samples {
+		binary.LittleEndian.PutUint32(buffer[i*4:], math.Float32bits(sample))
+	}
+	_, writer.err = writer.w.Write(buffer)
+}
+
+//Close returns the first error from writing. It does not close the underlying writer.
+func (writer *RawWriter) Close() error {
+	return writer.err
+}
@@ -0,0 +1,126 @@
+package nes
+
+import "encoding/gob"
+
+const (
+	//zapperRadius is how far, in pixels, from the aim point the Zapper's sensor sees.
+	zapperRadius = 2
+	//zapperBrightness is the luma, out of 255, a pixel needs for the sensor to see it.
+	zapperBrightness = 0xC0
+	//zapperPersistence is how long the sensor keeps seeing a lit pixel after the beam drew it,
+	//in PPU cycles. The phosphor and sensor together stay lit for about 20 scanlines.
+	zapperPersistence = 20 * 341
+)
+
+//lightSensor is implemented by devices that watch the picture as the PPU draws it.
+type lightSensor interface {
+	//attachPPU gives the device the PPU whose pixels it will be shown.
+	attachPPU(ppu *PPU)
+	//observePixel is called for every pixel as it's rendered.
+	observePixel(x int, y int, col uint32)
+}
+
+//Zapper is the NES light gun. In a controller port it drives D3 low while its sensor sees light
+//and D4 high while the trigger is pulled.
+type Zapper struct {
+	ppu     *PPU
+	x       int
+	y       int
+	aimed   bool
+	trigger bool
+	//lit is set once the sensor has seen a bright pixel, drawn at PPU cycle litAt.
+	lit   bool
+	litAt uint64
+}
+
+//NewZapper returns a Zapper aimed away from the screen.
+func NewZapper() *Zapper {
+	return &Zapper{}
+}
+
+//Aim points the Zapper at a pixel of the picture. Points off the picture aim away from the screen.
+func (zapper *Zapper) Aim(x int, y int) {
+	zapper.x, zapper.y = x, y
+	zapper.aimed = x >= 0 && x < Width && y >= 0 && y < Height
+}
+
+//SetTrigger pulls or releases the trigger.
+func (zapper *Zapper) SetTrigger(pulled bool) {
+	zapper.trigger = pulled
+}
+
+func (zapper *Zapper) attachPPU(ppu *PPU) {
+	zapper.ppu = ppu
+}
+
+func (zapper *Zapper) observePixel(x int, y int, col uint32) {
+	if !zapper.aimed || abs(x-zapper.x) > zapperRadius || abs(y-zapper.y) > zapperRadius {
+		return
+	}
+	r, g, b := (col>>16)&0xFF, (col>>8)&0xFF, col&0xFF
+	if (r*299+g*587+b*114)/1000 >= zapperBrightness {
+		zapper.lit, zapper.litAt = true, zapper.ppu.cycles
+	}
+}
+
+//sensesLight reports whether a bright pixel near the aim point was drawn recently enough to still be seen.
+func (zapper *Zapper) sensesLight() bool {
+	return zapper.aimed && zapper.lit && zapper.ppu != nil && zapper.ppu.cycles-zapper.litAt < zapperPersistence
+}
+
+//Read returns the trigger on D4 and the light sensor, low for light, on D3.
+func (zapper *Zapper) Read(port int) byte {
+	var data byte
+	if zapper.trigger {
+		data |= 0x10
+	}
+	if !zapper.sensesLight() {
+		data |= 0x08
+	}
+	return data
+}
+
+//Write does nothing; the Zapper has no use for the strobe.
+func (zapper *Zapper) Write(out byte) {
+}
+
+func (zapper *Zapper) Save(encoder *gob.Encoder) error {
+	return encodeAll(encoder, zapper.lit, zapper.litAt)
+}
+
+func (zapper *Zapper) Load(decoder *gob.Decoder) error {
+	return decodeAll(decoder, &zapper.lit, &zapper.litAt)
+}
+
+//MovieName identifies the Zapper in movies.
+func (zapper *Zapper) MovieName() string {
+	return "zapper"
+}
+
+//MovieInput records the aim point and the trigger.
+func (zapper *Zapper) MovieInput(input *[MovieInputSize]byte) {
+	input[0], input[1], input[2] = byte(zapper.x), byte(zapper.y), 0
+	if zapper.aimed {
+		input[2] |= 1
+	}
+	if zapper.trigger {
+		input[2] |= 2
+	}
+}
+
+//SetMovieInput plays back input recorded by MovieInput.
+func (zapper *Zapper) SetMovieInput(input *[MovieInputSize]byte) {
+	if input[2]&1 != 0 {
+		zapper.Aim(int(input[0]), int(input[1]))
+	} else {
+		zapper.Aim(-1, -1)
+	}
+	zapper.trigger = input[2]&2 != 0
+}
+
+func abs(x int) int {
+	if x < 0 {
+		return -x
+	}
+	return x
+}